@@ -5,9 +5,26 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/4lerman/pm_service/internal/service/attachments"
+	"github.com/4lerman/pm_service/internal/service/audit"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/internal/service/board"
+	"github.com/4lerman/pm_service/internal/service/comments"
+	"github.com/4lerman/pm_service/internal/service/fields"
+	"github.com/4lerman/pm_service/internal/service/filters"
+	"github.com/4lerman/pm_service/internal/service/labels"
+	"github.com/4lerman/pm_service/internal/service/links"
+	"github.com/4lerman/pm_service/internal/service/milestones"
 	"github.com/4lerman/pm_service/internal/service/projects"
+	"github.com/4lerman/pm_service/internal/service/reports"
+	"github.com/4lerman/pm_service/internal/service/search"
+	"github.com/4lerman/pm_service/internal/service/sprints"
 	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/internal/service/teams"
 	"github.com/4lerman/pm_service/internal/service/users"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/internal/service/worklogs"
+	"github.com/4lerman/pm_service/pkg/storage"
 	"github.com/gorilla/mux"
 
 	_ "github.com/4lerman/pm_service/docs" // Import the docs generated by Swag CLI
@@ -15,47 +32,132 @@ import (
 )
 
 type APIServer struct {
-	addr string
-	db   *sql.DB
+	addr  string
+	db    *sql.DB
+	files storage.Storage
 }
 
-func NewAPIServer(addr string, db *sql.DB) *APIServer {
+func NewAPIServer(addr string, db *sql.DB, files storage.Storage) *APIServer {
 	return &APIServer{
-		addr: addr,
-		db:   db,
+		addr:  addr,
+		db:    db,
+		files: files,
 	}
 }
 
-
 // @title Project Management Service
 // @version 1.0
 // @description This is a API server for project management service.
 // @host localhost:5000
 // @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func (s *APIServer) Run() error {
 	router := mux.NewRouter()
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-
 	subRouter := router.PathPrefix("/api/v1").Subrouter()
 
-	usersRouter := subRouter.PathPrefix("/users").Subrouter()
-	tasksRouter := subRouter.PathPrefix("/tasks").Subrouter()
-	projectsRouter := subRouter.PathPrefix("/projects").Subrouter()
-
 	usersStore := users.NewStore(s.db)
+
+	authRouter := subRouter.PathPrefix("/auth").Subrouter()
+	authStore := auth.NewStore(s.db)
+	authService := auth.NewHandler(authStore, usersStore)
+	authService.RegisterRoutes(authRouter)
+
+	protectedRouter := subRouter.NewRoute().Subrouter()
+	protectedRouter.Use(auth.WithJWTAuth(usersStore))
+
+	usersRouter := protectedRouter.PathPrefix("/users").Subrouter()
+	tasksRouter := protectedRouter.PathPrefix("/tasks").Subrouter()
+	projectsRouter := protectedRouter.PathPrefix("/projects").Subrouter()
+	searchRouter := protectedRouter.PathPrefix("/search").Subrouter()
+	auditRouter := protectedRouter.PathPrefix("/audit").Subrouter()
+	teamsRouter := protectedRouter.PathPrefix("/teams").Subrouter()
+
 	usersService := users.NewHandler(usersStore)
 	usersService.RegisterRoutes(usersRouter)
 
+	projectsStore := projects.NewStore(s.db)
+
+	teamsStore := teams.NewStore(s.db)
+	teamsService := teams.NewHandler(teamsStore, usersStore)
+	teamsService.RegisterRoutes(teamsRouter)
+
+	workflowsStore := workflows.NewStore(s.db)
+
+	milestonesStore := milestones.NewStore(s.db)
+
 	tasksStore := tasks.NewStore(s.db)
-	tasksService := tasks.NewHandler(tasksStore)
+	tasksService := tasks.NewHandler(tasksStore, projectsStore, workflowsStore, milestonesStore, s.files)
 	tasksService.RegisterRoutes(tasksRouter)
 
-	projectsStore := projects.NewStore(s.db)
-	projectsService := projects.NewHandler(projectsStore)
+	projectsService := projects.NewHandler(projectsStore, teamsStore, s.files)
 	projectsService.RegisterRoutes(projectsRouter)
 
+	workflowsService := workflows.NewHandler(workflowsStore, projectsStore)
+	workflowsService.RegisterRoutes(projectsRouter)
+
+	filtersStore := filters.NewStore(s.db)
+	filtersService := filters.NewHandler(filtersStore, tasksStore, projectsStore)
+	filtersService.RegisterRoutes(usersRouter)
+	filtersService.RegisterProjectRoutes(projectsRouter)
+
+	searchStore := search.NewStore(s.db)
+	searchService := search.NewHandler(searchStore)
+	searchService.RegisterRoutes(searchRouter)
+
+	commentsStore := comments.NewStore(s.db)
+	commentsService := comments.NewHandler(commentsStore, tasksStore, projectsStore)
+	commentsService.RegisterRoutes(tasksRouter)
+	commentsService.RegisterUserRoutes(usersRouter)
+
+	attachmentsStore := attachments.NewStore(s.db)
+	attachmentsService := attachments.NewHandler(attachmentsStore, tasksStore, projectsStore, s.files)
+	attachmentsService.RegisterRoutes(tasksRouter)
+
+	linksStore := links.NewStore(s.db)
+	linksService := links.NewHandler(linksStore, tasksStore, projectsStore)
+	linksService.RegisterRoutes(tasksRouter)
+
+	labelsStore := labels.NewStore(s.db)
+	labelsService := labels.NewHandler(labelsStore, tasksStore, projectsStore)
+	labelsService.RegisterRoutes(projectsRouter)
+	labelsService.RegisterTaskRoutes(tasksRouter)
+
+	fieldsStore := fields.NewStore(s.db)
+	fieldsService := fields.NewHandler(fieldsStore, tasksStore, projectsStore)
+	fieldsService.RegisterRoutes(projectsRouter)
+	fieldsService.RegisterTaskRoutes(tasksRouter)
+
+	worklogsStore := worklogs.NewStore(s.db)
+	worklogsService := worklogs.NewHandler(worklogsStore, tasksStore, projectsStore)
+	worklogsService.RegisterRoutes(tasksRouter)
+	worklogsService.RegisterUserRoutes(usersRouter)
+	worklogsService.RegisterProjectRoutes(projectsRouter)
+
+	sprintsStore := sprints.NewStore(s.db)
+	sprintsService := sprints.NewHandler(sprintsStore, tasksStore, projectsStore)
+	sprintsService.RegisterRoutes(projectsRouter)
+
+	milestonesService := milestones.NewHandler(milestonesStore, projectsStore)
+	milestonesService.RegisterRoutes(projectsRouter)
+
+	boardStore := board.NewStore(s.db)
+	boardService := board.NewHandler(boardStore, projectsStore)
+	boardService.RegisterRoutes(projectsRouter)
+
+	reportsStore := reports.NewStore(s.db)
+	reportsService := reports.NewHandler(reportsStore, projectsStore)
+	reportsService.RegisterRoutes(projectsRouter)
+
+	auditStore := audit.NewStore(s.db)
+	auditService := audit.NewHandler(auditStore)
+	auditService.RegisterRoutes(auditRouter)
+	auditService.RegisterTaskRoutes(tasksRouter)
+
 	log.Println("Listening on", s.addr)
 	return http.ListenAndServe(s.addr, router)
 }
@@ -7,10 +7,18 @@ import (
 
 	"github.com/4lerman/pm_service/cmd/pm_service/api"
 	"github.com/4lerman/pm_service/internal/config"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/internal/service/users"
 	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/pkg/storage"
+	"github.com/4lerman/pm_service/types"
 )
 
 func main() {
+	if config.Envs.JWTSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
 	db, err := db.NewPSQLStorage(&db.DbConfig{
 		Host:     config.Envs.DBAddress,
 		User:     config.Envs.DBUser,
@@ -26,8 +34,23 @@ func main() {
 	defer db.Close()
 
 	initStorage(db)
+	initAdmin(db)
+
+	files, err := storage.NewStorage(&storage.StorageConfig{
+		Driver:      config.Envs.StorageDriver,
+		LocalPath:   config.Envs.StoragePath,
+		S3Endpoint:  config.Envs.S3Endpoint,
+		S3AccessKey: config.Envs.S3AccessKey,
+		S3SecretKey: config.Envs.S3SecretKey,
+		S3Bucket:    config.Envs.S3Bucket,
+		S3UseSSL:    config.Envs.S3UseSSL,
+	})
+
+	if err != nil {
+		log.Fatal("File storage init error", err)
+	}
 
-	server := api.NewAPIServer(fmt.Sprint(":", config.Envs.Port), db)
+	server := api.NewAPIServer(fmt.Sprint(":", config.Envs.Port), db, files)
 
 	if err := server.Run(); err != nil {
 		log.Fatal("Error when running server: ", err)
@@ -41,3 +64,54 @@ func initStorage(db *sql.DB) {
 
 	log.Println("Db connected successfully!")
 }
+
+// Creates the bootstrap admin account when ADMIN_EMAIL and ADMIN_PASSWORD are set,
+// so that there is someone to log in as on a fresh database. A user with that
+// email but no password only gets the missing credentials
+func initAdmin(db *sql.DB) {
+	if config.Envs.AdminEmail == "" || config.Envs.AdminPassword == "" {
+		return
+	}
+
+	authStore := auth.NewStore(db)
+	if _, err := authStore.GetCredentialsByEmail(config.Envs.AdminEmail); err == nil {
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(config.Envs.AdminPassword)
+	if err != nil {
+		log.Fatal("Admin init error", err)
+	}
+
+	userStore := users.NewStore(db)
+
+	existing, err := userStore.GetUsersByEmail(config.Envs.AdminEmail)
+	if err != nil {
+		log.Fatal("Admin init error", err)
+	}
+
+	for _, user := range existing {
+		if user.Email != config.Envs.AdminEmail {
+			continue
+		}
+
+		if err := authStore.CreateCredentials(user.ID, passwordHash); err != nil {
+			log.Fatal("Admin init error", err)
+		}
+
+		log.Println("Admin credentials created:", user.Email)
+		return
+	}
+
+	err = userStore.CreateUser(types.User{
+		FullName: config.Envs.AdminFullName,
+		Email:    config.Envs.AdminEmail,
+		UserRole: types.Admin,
+	}, passwordHash, 0)
+
+	if err != nil {
+		log.Fatal("Admin init error", err)
+	}
+
+	log.Println("Admin user created:", config.Envs.AdminEmail)
+}
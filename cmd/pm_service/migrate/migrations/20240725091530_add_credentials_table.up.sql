@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS credentials (
+    userId INT PRIMARY KEY,
+    passwordHash VARCHAR(255) NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TYPE project_role AS ENUM ('owner', 'maintainer', 'contributor', 'viewer');
+
+CREATE TABLE IF NOT EXISTS project_members (
+    projectId INT NOT NULL,
+    userId INT NOT NULL,
+    projectRole project_role NOT NULL,
+    addedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (projectId, userId),
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO project_members (projectId, userId, projectRole)
+SELECT id, managerId, 'owner' FROM projects
+ON CONFLICT DO NOTHING;
+
+INSERT INTO project_members (projectId, userId, projectRole)
+SELECT DISTINCT projectId, userId, 'contributor'::project_role FROM tasks
+ON CONFLICT DO NOTHING;
\ No newline at end of file
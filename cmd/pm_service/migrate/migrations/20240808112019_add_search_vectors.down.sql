@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS tasks_search_idx;
+DROP INDEX IF EXISTS projects_search_idx;
+DROP INDEX IF EXISTS users_search_idx;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS searchVector;
+ALTER TABLE projects DROP COLUMN IF EXISTS searchVector;
+ALTER TABLE users DROP COLUMN IF EXISTS searchVector;
\ No newline at end of file
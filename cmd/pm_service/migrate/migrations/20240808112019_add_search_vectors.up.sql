@@ -0,0 +1,18 @@
+ALTER TABLE tasks ADD COLUMN searchVector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(descript, '')), 'B')
+) STORED;
+
+ALTER TABLE projects ADD COLUMN searchVector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(descript, '')), 'B')
+) STORED;
+
+ALTER TABLE users ADD COLUMN searchVector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(fullName, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(email, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS tasks_search_idx ON tasks USING GIN (searchVector);
+CREATE INDEX IF NOT EXISTS projects_search_idx ON projects USING GIN (searchVector);
+CREATE INDEX IF NOT EXISTS users_search_idx ON users USING GIN (searchVector);
\ No newline at end of file
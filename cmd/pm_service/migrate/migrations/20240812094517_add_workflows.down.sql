@@ -0,0 +1,21 @@
+CREATE TYPE task_priority AS ENUM ('new', 'in_process', 'done');
+
+ALTER TABLE tasks ADD COLUMN taskPriority task_priority;
+
+UPDATE tasks SET taskPriority = CASE workflow_states.category
+    WHEN 'todo' THEN 'new'::task_priority
+    WHEN 'in_progress' THEN 'in_process'::task_priority
+    ELSE 'done'::task_priority
+END
+FROM workflow_states
+WHERE workflow_states.projectId = tasks.projectId AND workflow_states.name = tasks.status;
+
+ALTER TABLE tasks
+    ALTER COLUMN taskPriority SET NOT NULL,
+    DROP COLUMN status;
+
+DROP TABLE IF EXISTS workflow_transitions;
+
+DROP TABLE IF EXISTS workflow_states;
+
+DROP TYPE status_category;
\ No newline at end of file
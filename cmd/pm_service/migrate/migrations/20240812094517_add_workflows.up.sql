@@ -0,0 +1,50 @@
+CREATE TYPE status_category AS ENUM ('todo', 'in_progress', 'done');
+
+CREATE TABLE IF NOT EXISTS workflow_states (
+    id SERIAL PRIMARY KEY,
+    projectId INT NOT NULL,
+    name VARCHAR(30) NOT NULL,
+    category status_category NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+
+    UNIQUE (projectId, name),
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS workflow_transitions (
+    projectId INT NOT NULL,
+    fromState VARCHAR(30) NOT NULL,
+    toState VARCHAR(30) NOT NULL,
+
+    PRIMARY KEY (projectId, fromState, toState),
+    FOREIGN KEY (projectId, fromState) REFERENCES workflow_states(projectId, name) ON DELETE CASCADE,
+    FOREIGN KEY (projectId, toState) REFERENCES workflow_states(projectId, name) ON DELETE CASCADE
+);
+
+INSERT INTO workflow_states (projectId, name, category, position)
+SELECT projects.id, states.name, states.category::status_category, states.position
+FROM projects CROSS JOIN (VALUES
+    ('new', 'todo', 0),
+    ('in_process', 'in_progress', 1),
+    ('done', 'done', 2)
+) AS states (name, category, position);
+
+INSERT INTO workflow_transitions (projectId, fromState, toState)
+SELECT projects.id, transitions.fromState, transitions.toState
+FROM projects CROSS JOIN (VALUES
+    ('new', 'in_process'),
+    ('in_process', 'new'),
+    ('in_process', 'done'),
+    ('done', 'in_process')
+) AS transitions (fromState, toState);
+
+ALTER TABLE tasks ADD COLUMN status VARCHAR(30);
+
+UPDATE tasks SET status = taskPriority::text;
+
+ALTER TABLE tasks
+    ALTER COLUMN status SET NOT NULL,
+    ADD FOREIGN KEY (projectId, status) REFERENCES workflow_states(projectId, name),
+    DROP COLUMN taskPriority;
+
+DROP TYPE task_priority;
\ No newline at end of file
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id SERIAL PRIMARY KEY,
+    entityType VARCHAR(20) NOT NULL,
+    entityId INT NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    actorId INT,
+    changes JSONB NOT NULL DEFAULT '{}',
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_entity_idx ON audit_events (entityType, entityId, id);
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actorId, id);
+
+CREATE INDEX IF NOT EXISTS audit_events_created_idx ON audit_events (createdAt);
+
+CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_immutable
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS comment_mentions;
+
+DROP TABLE IF EXISTS comment_revisions;
+
+DROP TABLE IF EXISTS comments;
\ No newline at end of file
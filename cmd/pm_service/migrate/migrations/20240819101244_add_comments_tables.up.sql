@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    taskId INT NOT NULL,
+    parentId INT,
+    authorId INT,
+    body TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (parentId) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (authorId) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS comments_task_idx ON comments (taskId, createdAt);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id SERIAL PRIMARY KEY,
+    commentId INT NOT NULL,
+    body TEXT NOT NULL,
+    editedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (commentId) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS comment_revisions_comment_idx ON comment_revisions (commentId);
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    commentId INT NOT NULL,
+    userId INT NOT NULL,
+
+    PRIMARY KEY (commentId, userId),
+    FOREIGN KEY (commentId) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS comment_mentions_user_idx ON comment_mentions (userId);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    taskId INT NOT NULL,
+    fileName VARCHAR(255) NOT NULL,
+    contentType VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    storageKey VARCHAR(255) NOT NULL UNIQUE,
+    uploaderId INT,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (uploaderId) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachments_task_idx ON attachments (taskId);
\ No newline at end of file
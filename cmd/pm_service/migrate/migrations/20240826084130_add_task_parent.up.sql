@@ -0,0 +1,3 @@
+ALTER TABLE tasks ADD COLUMN parentId INT REFERENCES tasks(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_parent_idx ON tasks (parentId);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS task_dependencies;
+
+DROP TYPE IF EXISTS link_type;
\ No newline at end of file
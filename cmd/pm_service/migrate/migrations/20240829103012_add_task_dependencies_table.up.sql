@@ -0,0 +1,16 @@
+CREATE TYPE link_type AS ENUM ('blocks', 'relates_to', 'duplicates');
+
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    id SERIAL PRIMARY KEY,
+    taskId INT NOT NULL,
+    targetId INT NOT NULL,
+    linkType link_type NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (taskId, targetId, linkType),
+    CHECK (taskId <> targetId),
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (targetId) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS task_dependencies_target_idx ON task_dependencies (targetId, linkType);
\ No newline at end of file
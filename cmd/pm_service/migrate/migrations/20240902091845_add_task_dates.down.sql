@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS tasks_due_date_idx;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS startDate,
+    DROP COLUMN IF EXISTS dueDate,
+    DROP COLUMN IF EXISTS completedAt;
\ No newline at end of file
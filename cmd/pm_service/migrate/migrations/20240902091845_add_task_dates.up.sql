@@ -0,0 +1,11 @@
+ALTER TABLE tasks
+    ADD COLUMN startDate DATE,
+    ADD COLUMN dueDate DATE,
+    ADD COLUMN completedAt TIMESTAMP,
+    ADD CHECK (dueDate >= startDate);
+
+UPDATE tasks SET completedAt = updatedAt
+FROM workflow_states
+WHERE workflow_states.projectId = tasks.projectId AND workflow_states.name = tasks.status AND workflow_states.category = 'done';
+
+CREATE INDEX IF NOT EXISTS tasks_due_date_idx ON tasks (dueDate) WHERE dueDate IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS labels (
+    id SERIAL PRIMARY KEY,
+    projectId INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    color CHAR(7) NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (projectId, name),
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS task_labels (
+    taskId INT NOT NULL,
+    labelId INT NOT NULL,
+
+    PRIMARY KEY (taskId, labelId),
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (labelId) REFERENCES labels(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS task_labels_label_idx ON task_labels (labelId);
\ No newline at end of file
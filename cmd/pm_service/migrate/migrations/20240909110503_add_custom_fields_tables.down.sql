@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS task_field_values;
+
+DROP TABLE IF EXISTS custom_fields;
+
+DROP TYPE IF EXISTS custom_field_type;
\ No newline at end of file
@@ -0,0 +1,29 @@
+CREATE TYPE custom_field_type AS ENUM ('text', 'number', 'date', 'enum', 'user');
+
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id SERIAL PRIMARY KEY,
+    projectId INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    fieldType custom_field_type NOT NULL,
+    options TEXT[] NOT NULL DEFAULT '{}',
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (projectId, name),
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS task_field_values (
+    taskId INT NOT NULL,
+    fieldId INT NOT NULL,
+    textValue TEXT,
+    numberValue DOUBLE PRECISION,
+    dateValue DATE,
+    userValue INT,
+
+    PRIMARY KEY (taskId, fieldId),
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (fieldId) REFERENCES custom_fields(id) ON DELETE CASCADE,
+    FOREIGN KEY (userValue) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS task_field_values_field_idx ON task_field_values (fieldId);
\ No newline at end of file
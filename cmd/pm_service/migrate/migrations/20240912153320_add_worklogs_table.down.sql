@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS worklogs;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS originalEstimate,
+    DROP COLUMN IF EXISTS remainingEstimate;
\ No newline at end of file
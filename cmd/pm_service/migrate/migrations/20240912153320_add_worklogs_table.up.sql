@@ -0,0 +1,21 @@
+ALTER TABLE tasks
+    ADD COLUMN originalEstimate INT CHECK (originalEstimate >= 0),
+    ADD COLUMN remainingEstimate INT CHECK (remainingEstimate >= 0);
+
+CREATE TABLE IF NOT EXISTS worklogs (
+    id SERIAL PRIMARY KEY,
+    taskId INT NOT NULL,
+    userId INT,
+    startedAt TIMESTAMP NOT NULL,
+    durationMinutes INT NOT NULL CHECK (durationMinutes > 0),
+    note TEXT NOT NULL DEFAULT '',
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (taskId) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS worklogs_task_idx ON worklogs (taskId, startedAt);
+
+CREATE INDEX IF NOT EXISTS worklogs_user_idx ON worklogs (userId, startedAt);
\ No newline at end of file
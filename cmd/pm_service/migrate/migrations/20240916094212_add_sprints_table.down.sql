@@ -0,0 +1,5 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS sprintId;
+
+DROP TABLE IF EXISTS sprints;
+
+DROP TYPE IF EXISTS sprint_state;
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TYPE sprint_state AS ENUM ('planned', 'active', 'closed');
+
+CREATE TABLE IF NOT EXISTS sprints (
+    id SERIAL PRIMARY KEY,
+    projectId INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    goal TEXT NOT NULL DEFAULT '',
+    startDate DATE,
+    endDate DATE,
+    state sprint_state NOT NULL DEFAULT 'planned',
+    completedAt TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (endDate >= startDate),
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS sprints_active_idx ON sprints (projectId) WHERE state = 'active';
+
+ALTER TABLE tasks ADD COLUMN sprintId INT REFERENCES sprints(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_sprint_idx ON tasks (sprintId);
\ No newline at end of file
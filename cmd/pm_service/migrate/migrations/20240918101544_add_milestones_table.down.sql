@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS milestoneId;
+
+DROP TABLE IF EXISTS milestones;
\ No newline at end of file
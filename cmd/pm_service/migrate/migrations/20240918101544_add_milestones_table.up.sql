@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS milestones (
+    id SERIAL PRIMARY KEY,
+    projectId INT NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    descript TEXT NOT NULL DEFAULT '',
+    targetDate DATE,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (projectId) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS milestones_project_idx ON milestones (projectId, targetDate);
+
+ALTER TABLE tasks ADD COLUMN milestoneId INT REFERENCES milestones(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_milestone_idx ON tasks (milestoneId);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS rank;
+
+ALTER TABLE workflow_states DROP COLUMN IF EXISTS wipLimit;
\ No newline at end of file
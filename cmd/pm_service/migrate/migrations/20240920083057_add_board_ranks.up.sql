@@ -0,0 +1,14 @@
+ALTER TABLE workflow_states ADD COLUMN wipLimit INT CHECK (wipLimit > 0);
+
+ALTER TABLE tasks ADD COLUMN rank TEXT COLLATE "C";
+
+UPDATE tasks SET rank = ranked.rank
+FROM (
+    SELECT id, 'i' || lpad(to_hex(row_number() OVER (PARTITION BY projectId ORDER BY id)), 5, '0') || 'i' AS rank
+    FROM tasks
+) AS ranked
+WHERE ranked.id = tasks.id;
+
+ALTER TABLE tasks ALTER COLUMN rank SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_rank_idx ON tasks (projectId, rank);
\ No newline at end of file
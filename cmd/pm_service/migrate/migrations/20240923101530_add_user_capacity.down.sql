@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS tasks_user_due_date_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS weeklyCapacity;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN weeklyCapacity INT NOT NULL DEFAULT 40 CHECK (weeklyCapacity BETWEEN 0 AND 168);
+
+CREATE INDEX IF NOT EXISTS tasks_user_due_date_idx ON tasks (userId, dueDate);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS teamId;
+
+DROP TABLE IF EXISTS team_members;
+
+DROP TABLE IF EXISTS teams;
\ No newline at end of file
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    descript TEXT NOT NULL DEFAULT '',
+    leadId INT,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (leadId) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    teamId INT NOT NULL,
+    userId INT NOT NULL,
+    addedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (teamId, userId),
+    FOREIGN KEY (teamId) REFERENCES teams(id) ON DELETE CASCADE,
+    FOREIGN KEY (userId) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_idx ON team_members (userId);
+
+ALTER TABLE projects ADD COLUMN teamId INT REFERENCES teams(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS projects_team_idx ON projects (teamId);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE worklogs DROP COLUMN IF EXISTS deductedMinutes;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE worklogs ADD COLUMN deductedMinutes INT NOT NULL DEFAULT 0 CHECK (deductedMinutes >= 0);
\ No newline at end of file
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +16,25 @@ type Config struct {
 	DBAddress  string
 	DBPort     int64
 	DBName     string
+
+	JWTSecret                     string
+	JWTAccessExpirationInSeconds  int64
+	JWTRefreshExpirationInSeconds int64
+
+	AdminFullName string
+	AdminEmail    string
+	AdminPassword string
+
+	StorageDriver string
+	StoragePath   string
+	S3Endpoint    string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3Bucket      string
+	S3UseSSL      bool
+
+	AttachmentMaxSize      int64
+	AttachmentContentTypes []string
 }
 
 var Envs = initConfig()
@@ -30,6 +50,28 @@ func initConfig() Config {
 		DBAddress:  getEnv("DB_HOST", "127.0.0.1"),
 		DBPort:     getEnvAsInt("DB_PORT", 5432),
 		DBName:     getEnv("DB_NAME", "ecom"),
+
+		JWTSecret:                     getEnv("JWT_SECRET", ""),
+		JWTAccessExpirationInSeconds:  getEnvAsInt("JWT_ACCESS_EXP", 60*15),
+		JWTRefreshExpirationInSeconds: getEnvAsInt("JWT_REFRESH_EXP", 3600*24*7),
+
+		AdminFullName: getEnv("ADMIN_FULL_NAME", "Administrator"),
+		AdminEmail:    getEnv("ADMIN_EMAIL", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		StorageDriver: getEnv("STORAGE_DRIVER", "local"),
+		StoragePath:   getEnv("STORAGE_PATH", "uploads"),
+		S3Endpoint:    getEnv("S3_ENDPOINT", "localhost:9000"),
+		S3AccessKey:   getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:   getEnv("S3_SECRET_KEY", ""),
+		S3Bucket:      getEnv("S3_BUCKET", "attachments"),
+		S3UseSSL:      getEnv("S3_USE_SSL", "false") == "true",
+
+		AttachmentMaxSize: getEnvAsInt("ATTACHMENT_MAX_SIZE", 10<<20),
+		AttachmentContentTypes: getEnvAsList("ATTACHMENT_CONTENT_TYPES", []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp",
+			"text/plain", "application/pdf", "application/zip", "application/x-gzip",
+		}),
 	}
 }
 
@@ -42,6 +84,22 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Reads a comma separated list
+func getEnvAsList(key string, fallback []string) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+
+		return list
+	}
+
+	return fallback
+}
+
 func getEnvAsInt(key string, fallback int64) int64 {
 	if value, ok := os.LookupEnv(key); ok {
 		i, err := strconv.ParseInt(value, 10, 64)
@@ -0,0 +1,56 @@
+package jql
+
+import (
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/types"
+)
+
+type fieldKind int
+
+const (
+	kindInt fieldKind = iota
+	kindText
+	kindEnum
+	kindTime
+)
+
+// field maps a query field to its column on the tasks table. SortKey is the
+// name of the field in tasks.Keyset, empty if the field cannot be ordered by.
+// Nullable fields can be tested with IS EMPTY and IS NOT EMPTY.
+type field struct {
+	column   string
+	sortKey  string
+	kind     fieldKind
+	values   []string
+	user     bool
+	nullable bool
+}
+
+var fields = map[string]field{
+	"id":        {column: "id", sortKey: "id", kind: kindInt},
+	"title":     {column: "title", sortKey: "title", kind: kindText},
+	"project":   {column: "projectId", sortKey: "project_id", kind: kindInt},
+	"assignee":  {column: "userId", sortKey: "user_id", kind: kindInt, user: true},
+	"parent":    {column: "parentId", kind: kindInt, nullable: true},
+	"sprint":    {column: "sprintId", kind: kindInt, nullable: true},
+	"milestone": {column: "milestoneId", kind: kindInt, nullable: true},
+	"status":    {column: "status", sortKey: "status", kind: kindText},
+	"category": {column: workflows.CategoryColumn, kind: kindEnum, values: []string{
+		string(types.Todo), string(types.InProgress), string(types.Done),
+	}},
+	"priority": {column: "taskType", sortKey: "task_type", kind: kindEnum, values: []string{
+		string(types.Low), string(types.Medium), string(types.High),
+	}},
+	"start_date":   {column: "startDate", kind: kindTime, nullable: true},
+	"due_date":     {column: "dueDate", kind: kindTime, nullable: true},
+	"completed_at": {column: "completedAt", kind: kindTime, nullable: true},
+	"created_at":   {column: "createdAt", sortKey: "created_at", kind: kindTime},
+	"updated_at":   {column: "updatedAt", sortKey: "updated_at", kind: kindTime},
+}
+
+var operators = map[fieldKind][]string{
+	kindInt:  {"=", "!=", "<", "<=", ">", ">=", "IN", "NOT IN"},
+	kindText: {"=", "!=", "~", "!~", "IN", "NOT IN"},
+	kindEnum: {"=", "!=", "IN", "NOT IN"},
+	kindTime: {"=", "!=", "<", "<=", ">", ">="},
+}
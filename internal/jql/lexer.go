@@ -0,0 +1,155 @@
+package jql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of query"
+	case tokIdent:
+		return "identifier"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	case tokOp:
+		return "operator"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokComma:
+		return "','"
+	}
+
+	return "token"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Error is a syntax or semantic error in a query. Pos is the 0-based byte
+// offset in the query where the offending token starts.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+
+	for i < len(input) {
+		c := rune(input[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+
+		case strings.ContainsRune("=!<>~", c):
+			op := string(c)
+			if i+1 < len(input) {
+				switch two := input[i : i+2]; two {
+				case "!=", "<=", ">=", "!~":
+					op = two
+				}
+			}
+
+			if op == "!" {
+				return nil, errorf(i, "unexpected character '!'")
+			}
+
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+
+		case c == '"' || c == '\'':
+			start := i
+			value := strings.Builder{}
+			i++
+
+			for {
+				if i >= len(input) {
+					return nil, errorf(start, "unterminated string")
+				}
+
+				if rune(input[i]) == c {
+					i++
+					break
+				}
+
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				}
+
+				value.WriteByte(input[i])
+				i++
+			}
+
+			tokens = append(tokens, token{tokString, value.String(), start})
+
+		// Numbers, and dates and times which may be written unquoted in full
+		// RFC 3339 syntax such as 2024-05-01T09:30:00.5+05:00
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(input) && unicode.IsDigit(rune(input[i+1]))):
+			start := i
+			i++
+			for i < len(input) && (unicode.IsDigit(rune(input[i])) || strings.ContainsRune("-:.+TZ", rune(input[i]))) {
+				i++
+			}
+
+			tokens = append(tokens, token{tokNumber, input[start:i], start})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(input) && (unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i])) || input[i] == '_' || input[i] == '.') {
+				i++
+			}
+
+			tokens = append(tokens, token{tokIdent, input[start:i], start})
+
+		default:
+			return nil, errorf(i, "unexpected character %q", c)
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", len(input)}), nil
+}
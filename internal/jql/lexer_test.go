@@ -0,0 +1,70 @@
+package jql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []token
+	}{
+		{"id = 4", []token{{tokIdent, "id", 0}, {tokOp, "=", 3}, {tokNumber, "4", 5}}},
+		{"title!~'a b'", []token{{tokIdent, "title", 0}, {tokOp, "!~", 5}, {tokString, "a b", 7}}},
+		{`title = "say \"hi\""`, []token{{tokIdent, "title", 0}, {tokOp, "=", 6}, {tokString, `say "hi"`, 8}}},
+		{"id in (1,-2)", []token{
+			{tokIdent, "id", 0}, {tokIdent, "in", 3}, {tokLParen, "(", 6},
+			{tokNumber, "1", 7}, {tokComma, ",", 8}, {tokNumber, "-2", 9}, {tokRParen, ")", 11},
+		}},
+		{"due_date >= 2024-05-01", []token{{tokIdent, "due_date", 0}, {tokOp, ">=", 9}, {tokNumber, "2024-05-01", 12}}},
+		{"updated_at < 2024-05-01T09:30:00Z", []token{{tokIdent, "updated_at", 0}, {tokOp, "<", 11}, {tokNumber, "2024-05-01T09:30:00Z", 13}}},
+		{"updated_at<2024-05-01T09:30:00.5+05:00", []token{{tokIdent, "updated_at", 0}, {tokOp, "<", 10}, {tokNumber, "2024-05-01T09:30:00.5+05:00", 11}}},
+	}
+
+	for _, test := range tests {
+		tokens, err := lex(test.input)
+		if err != nil {
+			t.Errorf("lex(%q) error: %v", test.input, err)
+			continue
+		}
+
+		want := append(test.want, token{tokEOF, "", len(test.input)})
+		if len(tokens) != len(want) {
+			t.Errorf("lex(%q) = %v, want %v", test.input, tokens, want)
+			continue
+		}
+
+		for i := range want {
+			if tokens[i] != want[i] {
+				t.Errorf("lex(%q) token %d = %v, want %v", test.input, i, tokens[i], want[i])
+			}
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"id ! 4", 3},
+		{"title = 'open", 8},
+		{"id = 4 & 5", 7},
+		{"id = #", 5},
+	}
+
+	for _, test := range tests {
+		_, err := lex(test.input)
+
+		var jqlErr *Error
+		if !errors.As(err, &jqlErr) {
+			t.Errorf("lex(%q) error = %v, want *Error", test.input, err)
+			continue
+		}
+
+		if jqlErr.Pos != test.pos {
+			t.Errorf("lex(%q) error at %d, want %d: %v", test.input, jqlErr.Pos, test.pos, err)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Package jql compiles task query expressions such as
+//
+//	project = 4 AND status != done AND assignee in (3, 7) ORDER BY updated_at DESC
+//
+// into parameterised SQL predicates over the tasks table. Values never
+// reach the SQL text; they are always bound as arguments.
+package jql
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Options supplies the context some functions need, e.g. currentUser()
+type Options struct {
+	CurrentUserId int
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	opts   Options
+	args   []any
+}
+
+// Compiles a query into a task predicate. Errors are of type *Error and
+// point at the offending position in the input.
+func Compile(input string, opts Options) (*types.TaskQuery, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, opts: opts}
+	query := &types.TaskQuery{}
+
+	if p.peek().kind != tokEOF && !p.isKeyword(p.peek(), "ORDER") {
+		if query.Where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.isKeyword(p.peek(), "ORDER") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+
+		if query.Sort, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errorf(tok.pos, "unexpected %s %q", tok.kind, tok.text)
+	}
+
+	query.Args = p.args
+	return query, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if tok := p.next(); !p.isKeyword(tok, keyword) {
+		return errorf(tok.pos, "expected %s, got %s %q", keyword, tok.kind, tok.text)
+	}
+
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, errorf(tok.pos, "expected %s, got %s %q", kind, tok.kind, tok.text)
+	}
+
+	return tok, nil
+}
+
+func (p *parser) arg(value any) string {
+	p.args = append(p.args, value)
+	return fmt.Sprintf("$%d", len(p.args))
+}
+
+func (p *parser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+
+	for p.isKeyword(p.peek(), "OR") {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+
+		left = "(" + left + " OR " + right + ")"
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+
+	for p.isKeyword(p.peek(), "AND") {
+		p.next()
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+
+		left = "(" + left + " AND " + right + ")"
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (string, error) {
+	tok := p.peek()
+
+	if p.isKeyword(tok, "NOT") {
+		p.next()
+
+		expr, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+
+		return "NOT (" + expr + ")", nil
+	}
+
+	if tok.kind == tokLParen {
+		p.next()
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := p.expect(tokRParen); err != nil {
+			return "", err
+		}
+
+		return "(" + expr + ")", nil
+	}
+
+	return p.parseClause()
+}
+
+func (p *parser) parseClause() (string, error) {
+	name := p.next()
+	if name.kind != tokIdent {
+		return "", errorf(name.pos, "expected field name, got %s %q", name.kind, name.text)
+	}
+
+	f, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return "", errorf(name.pos, "unknown field %q", name.text)
+	}
+
+	opTok := p.next()
+	op := opTok.text
+	switch {
+	case p.isKeyword(opTok, "IS"):
+		return p.parseEmpty(f, name)
+	case opTok.kind == tokOp:
+	case p.isKeyword(opTok, "IN"):
+		op = "IN"
+	case p.isKeyword(opTok, "NOT") && p.isKeyword(p.peek(), "IN"):
+		p.next()
+		op = "NOT IN"
+	default:
+		return "", errorf(opTok.pos, "expected operator after %q, got %s %q", name.text, opTok.kind, opTok.text)
+	}
+
+	if !slices.Contains(operators[f.kind], op) {
+		return "", errorf(opTok.pos, "operator %s cannot be used with field %q", op, name.text)
+	}
+
+	if op == "IN" || op == "NOT IN" {
+		values, err := p.parseList(f)
+		if err != nil {
+			return "", err
+		}
+
+		condition := fmt.Sprintf("%s = ANY(%s)", f.column, p.arg(pq.Array(values)))
+		if op == "NOT IN" && f.nullable {
+			// Empty fields hold none of the values, as with !=
+			return "NOT COALESCE(" + condition + ", false)", nil
+		}
+
+		if op == "NOT IN" {
+			condition = "NOT " + condition
+		}
+
+		return condition, nil
+	}
+
+	value, err := p.parseValue(f)
+	if err != nil {
+		return "", err
+	}
+
+	switch op {
+	case "~":
+		return fmt.Sprintf("%s ILIKE %s", f.column, p.arg("%"+db.EscapeLike(value.(string))+"%")), nil
+	case "!~":
+		return fmt.Sprintf("%s NOT ILIKE %s", f.column, p.arg("%"+db.EscapeLike(value.(string))+"%")), nil
+	}
+
+	if op == "!=" && f.nullable {
+		return fmt.Sprintf("%s IS DISTINCT FROM %s", f.column, p.arg(value)), nil
+	}
+
+	return fmt.Sprintf("%s %s %s", f.column, op, p.arg(value)), nil
+}
+
+// Parses the rest of IS [NOT] EMPTY after the IS keyword
+func (p *parser) parseEmpty(f field, name token) (string, error) {
+	negate := false
+	if p.isKeyword(p.peek(), "NOT") {
+		p.next()
+		negate = true
+	}
+
+	tok := p.next()
+	if !p.isKeyword(tok, "EMPTY") {
+		return "", errorf(tok.pos, "expected EMPTY, got %s %q", tok.kind, tok.text)
+	}
+
+	if !f.nullable {
+		return "", errorf(name.pos, "field %q is never empty", name.text)
+	}
+
+	if negate {
+		return f.column + " IS NOT NULL", nil
+	}
+
+	return f.column + " IS NULL", nil
+}
+
+func (p *parser) parseList(f field) ([]any, error) {
+	if _, err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+
+	values := []any{}
+	for {
+		value, err := p.parseValue(f)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+
+		tok := p.next()
+		if tok.kind == tokRParen {
+			return values, nil
+		}
+
+		if tok.kind != tokComma {
+			return nil, errorf(tok.pos, "expected ',' or ')', got %s %q", tok.kind, tok.text)
+		}
+	}
+}
+
+func (p *parser) parseValue(f field) (any, error) {
+	tok := p.next()
+
+	if tok.kind == tokIdent && f.user && p.peek().kind == tokLParen {
+		if !strings.EqualFold(tok.text, "currentUser") {
+			return nil, errorf(tok.pos, "unknown function %q", tok.text)
+		}
+
+		p.next()
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+
+		return p.opts.CurrentUserId, nil
+	}
+
+	if tok.kind != tokIdent && tok.kind != tokNumber && tok.kind != tokString {
+		return nil, errorf(tok.pos, "expected value, got %s %q", tok.kind, tok.text)
+	}
+
+	switch f.kind {
+	case kindInt:
+		value, err := strconv.Atoi(tok.text)
+		if err != nil || tok.kind == tokIdent {
+			return nil, errorf(tok.pos, "expected integer, got %q", tok.text)
+		}
+
+		return value, nil
+
+	case kindEnum:
+		value := strings.ToLower(tok.text)
+		if !slices.Contains(f.values, value) {
+			return nil, errorf(tok.pos, "invalid value %q, expected one of %s", tok.text, strings.Join(f.values, ", "))
+		}
+
+		return value, nil
+
+	case kindTime:
+		// Timestamps are stored in UTC without a zone, and postgres ignores
+		// the offset of a value bound to such a column
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if value, err := time.Parse(layout, tok.text); err == nil {
+				return value.UTC(), nil
+			}
+		}
+
+		return nil, errorf(tok.pos, "invalid date %q, expected YYYY-MM-DD or RFC 3339", tok.text)
+	}
+
+	return tok.text, nil
+}
+
+func (p *parser) parseOrderBy() ([]types.SortKey, error) {
+	keys := []types.SortKey{}
+
+	for {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, errorf(name.pos, "expected field name, got %s %q", name.kind, name.text)
+		}
+
+		f, ok := fields[strings.ToLower(name.text)]
+		if !ok || f.sortKey == "" {
+			return nil, errorf(name.pos, "cannot order by %q", name.text)
+		}
+
+		key := types.SortKey{Field: f.sortKey}
+		if p.isKeyword(p.peek(), "DESC") {
+			p.next()
+			key.Desc = true
+		} else if p.isKeyword(p.peek(), "ASC") {
+			p.next()
+		}
+
+		keys = append(keys, key)
+
+		if p.peek().kind != tokComma {
+			return keys, nil
+		}
+
+		p.next()
+	}
+}
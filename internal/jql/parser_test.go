@@ -0,0 +1,135 @@
+package jql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input string
+		where string
+		args  []any
+	}{
+		{"", "", nil},
+		{"id = 4", "id = $1", []any{4}},
+		{"assignee = currentUser()", "userId = $1", []any{7}},
+		{"priority = HIGH", "taskType = $1", []any{"high"}},
+		{"title ~ '50%_off'", "title ILIKE $1", []any{`%50\%\_off%`}},
+
+		// AND binds tighter than OR, NOT tighter than AND
+		{"id = 1 OR id = 2 AND id = 3", "(id = $1 OR (id = $2 AND id = $3))", []any{1, 2, 3}},
+		{"id = 1 AND id = 2 OR id = 3", "((id = $1 AND id = $2) OR id = $3)", []any{1, 2, 3}},
+		{"NOT id = 1 AND id = 2", "(NOT (id = $1) AND id = $2)", []any{1, 2}},
+		{"(id = 1 OR id = 2) AND id = 3", "(((id = $1 OR id = $2)) AND id = $3)", []any{1, 2, 3}},
+		{"id = 1 or id = 2 or id = 3", "((id = $1 OR id = $2) OR id = $3)", []any{1, 2, 3}},
+
+		// Empty nullable fields
+		{"sprint IS EMPTY", "sprintId IS NULL", nil},
+		{"due_date is not empty", "dueDate IS NOT NULL", nil},
+		{"milestone != 2", "milestoneId IS DISTINCT FROM $1", []any{2}},
+		{"project != 2", "projectId != $1", []any{2}},
+
+		{
+			"due_date < 2024-05-01T09:30:00+05:00",
+			"dueDate < $1",
+			[]any{time.Date(2024, 5, 1, 4, 30, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, test := range tests {
+		query, err := Compile(test.input, Options{CurrentUserId: 7})
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", test.input, err)
+			continue
+		}
+
+		if query.Where != test.where {
+			t.Errorf("Compile(%q).Where = %q, want %q", test.input, query.Where, test.where)
+		}
+
+		if len(query.Args) != len(test.args) {
+			t.Errorf("Compile(%q).Args = %v, want %v", test.input, query.Args, test.args)
+			continue
+		}
+
+		for i, arg := range query.Args {
+			if want, ok := test.args[i].(time.Time); ok {
+				if got, _ := arg.(time.Time); !got.Equal(want) || got.Location() != time.UTC {
+					t.Errorf("Compile(%q) arg %d = %v, want %v", test.input, i, arg, want)
+				}
+
+				continue
+			}
+
+			if !reflect.DeepEqual(arg, test.args[i]) {
+				t.Errorf("Compile(%q) arg %d = %#v, want %#v", test.input, i, arg, test.args[i])
+			}
+		}
+	}
+}
+
+func TestCompileIn(t *testing.T) {
+	query, err := Compile("parent NOT IN (1, 2) AND id in (3)", Options{})
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+
+	want := "(NOT COALESCE(parentId = ANY($1), false) AND id = ANY($2))"
+	if query.Where != want {
+		t.Errorf("Where = %q, want %q", query.Where, want)
+	}
+}
+
+func TestCompileOrderBy(t *testing.T) {
+	query, err := Compile("project = 1 ORDER BY updated_at DESC, title", Options{})
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+
+	want := []types.SortKey{{Field: "updated_at", Desc: true}, {Field: "title"}}
+	if !reflect.DeepEqual(query.Sort, want) {
+		t.Errorf("Sort = %v, want %v", query.Sort, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"owner = 1", 0},
+		{"id = 1 AND", 10},
+		{"id 1", 3},
+		{"title < 'a'", 6},
+		{"id = abc", 5},
+		{"priority = urgent", 11},
+		{"due_date = someday", 11},
+		{"assignee = me()", 11},
+		{"(id = 1", 7},
+		{"id in (1 2)", 9},
+		{"id = 1 id = 2", 7},
+		{"project IS EMPTY", 0},
+		{"sprint IS NOTHING", 10},
+		{"id = 1 ORDER updated_at", 13},
+		{"ORDER BY status, descript", 17},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.input, Options{})
+
+		var jqlErr *Error
+		if !errors.As(err, &jqlErr) {
+			t.Errorf("Compile(%q) error = %v, want *Error", test.input, err)
+			continue
+		}
+
+		if jqlErr.Pos != test.pos {
+			t.Errorf("Compile(%q) error at %d, want %d: %v", test.input, jqlErr.Pos, test.pos, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+type Action string
+
+const (
+	ManageUsers Action = "manage users"
+	ViewAudit   Action = "view audit trail"
+
+	CreateTeam Action = "create team"
+	ManageTeam Action = "manage team"
+
+	CreateProject Action = "create project"
+	UpdateProject Action = "update project"
+	DeleteProject Action = "delete project"
+
+	ManageProjectMembers Action = "manage project members"
+	ManageProjectAdmins  Action = "grant or revoke the owner and maintainer roles"
+	ManageWorkflow       Action = "manage workflow"
+	ManageLabels         Action = "manage labels"
+	ManageFields         Action = "manage custom fields"
+	ManageSprints        Action = "manage sprints"
+	ManageMilestones     Action = "manage milestones"
+
+	ManageFilter Action = "manage saved filter"
+	ViewFilter   Action = "view saved filter"
+
+	CreateTask Action = "create task"
+	UpdateTask Action = "update task"
+	DeleteTask Action = "delete task"
+
+	CreateComment Action = "comment on task"
+	UpdateComment Action = "edit comment"
+	DeleteComment Action = "delete comment"
+	ViewMentions  Action = "view mentions"
+
+	UploadAttachment Action = "upload attachment"
+	DeleteAttachment Action = "delete attachment"
+
+	LogWork        Action = "log work"
+	ManageWorklog  Action = "manage worklog"
+	ViewTimeReport Action = "view time report"
+)
+
+// Resource describes the ownership of the object an action is performed on.
+// Fields that do not apply to the action are left zero. OwnerId is the user
+// a personal object belongs to; MemberRole is the acting user's role in the
+// project the object belongs to, if any.
+type Resource struct {
+	OwnerId          int
+	ProjectManagerId int
+	AssigneeId       int
+	MemberRole       types.ProjectRole
+}
+
+type Rule func(user *types.User, resource Resource) bool
+
+var rules = map[Action]Rule{
+	ManageUsers: HasRole(types.Admin),
+	ViewAudit:   HasRole(types.Admin),
+
+	CreateTeam: HasRole(types.Admin, types.Manager),
+	ManageTeam: AnyOf(HasRole(types.Admin), IsOwner),
+
+	CreateProject: HasRole(types.Admin, types.Manager),
+	UpdateProject: AnyOf(HasRole(types.Admin), IsProjectManager),
+	DeleteProject: AnyOf(HasRole(types.Admin), IsProjectManager),
+
+	ManageProjectMembers: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ManageProjectAdmins:  AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner)),
+	ManageWorkflow:       AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ManageLabels:         AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ManageFields:         AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ManageSprints:        AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ManageMilestones:     AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+
+	ManageFilter: AnyOf(HasRole(types.Admin), IsOwner),
+	ViewFilter:   AnyOf(HasRole(types.Admin), IsOwner, HasProjectRole(types.Owner, types.Maintainer, types.Contributor, types.Viewer)),
+
+	CreateTask: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	UpdateTask: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer), IsAssignee),
+	DeleteTask: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+
+	CreateComment: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer, types.Contributor, types.Viewer)),
+	UpdateComment: IsOwner,
+	DeleteComment: AnyOf(HasRole(types.Admin), IsOwner, IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ViewMentions:  AnyOf(HasRole(types.Admin), IsOwner),
+
+	UploadAttachment: AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer, types.Contributor), IsAssignee),
+	DeleteAttachment: AnyOf(HasRole(types.Admin), IsOwner, IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+
+	LogWork:        AnyOf(HasRole(types.Admin), IsProjectManager, HasProjectRole(types.Owner, types.Maintainer, types.Contributor), IsAssignee),
+	ManageWorklog:  AnyOf(HasRole(types.Admin), IsOwner, IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+	ViewTimeReport: AnyOf(HasRole(types.Admin), IsOwner, IsProjectManager, HasProjectRole(types.Owner, types.Maintainer)),
+}
+
+func Can(user *types.User, action Action, resource Resource) bool {
+	rule, ok := rules[action]
+	if !ok || user == nil {
+		return false
+	}
+
+	return rule(user, resource)
+}
+
+// Returns an error wrapping ErrForbidden when the user may not perform the action
+func Authorize(user *types.User, action Action, resource Resource) error {
+	if !Can(user, action, resource) {
+		return fmt.Errorf("%w: not allowed to %s", ErrForbidden, action)
+	}
+
+	return nil
+}
+
+func HasRole(roles ...types.UserRole) Rule {
+	return func(user *types.User, _ Resource) bool {
+		for _, role := range roles {
+			if user.UserRole == role {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func HasProjectRole(roles ...types.ProjectRole) Rule {
+	return func(_ *types.User, resource Resource) bool {
+		for _, role := range roles {
+			if resource.MemberRole == role {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func AnyOf(rules ...Rule) Rule {
+	return func(user *types.User, resource Resource) bool {
+		for _, rule := range rules {
+			if rule(user, resource) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func IsOwner(user *types.User, resource Resource) bool {
+	return resource.OwnerId != 0 && resource.OwnerId == user.ID
+}
+
+func IsProjectManager(user *types.User, resource Resource) bool {
+	return resource.ProjectManagerId != 0 && resource.ProjectManagerId == user.ID
+}
+
+func IsAssignee(user *types.User, resource Resource) bool {
+	return resource.AssigneeId != 0 && resource.AssigneeId == user.ID
+}
+
+// Looks up the membership of a user in a project
+type ProjectMembers interface {
+	GetProjectMember(projectId int, userId int) (*types.ProjectMember, error)
+}
+
+// Returns the role of the user in the project, empty when they are not a member
+func ProjectRole(members ProjectMembers, projectId int, user *types.User) types.ProjectRole {
+	if user == nil {
+		return ""
+	}
+
+	member, err := members.GetProjectMember(projectId, user.ID)
+	if err != nil {
+		return ""
+	}
+
+	return member.ProjectRole
+}
+
+// Authorizes an action on the project or one of its objects. The resource
+// carries the object's owner and assignee, if any; the project manager and the
+// user's role in the project are filled in
+func AuthorizeProject(members ProjectMembers, user *types.User, action Action, project *types.Project, resource Resource) error {
+	resource.ProjectManagerId = project.ManagerId
+	resource.MemberRole = ProjectRole(members, project.ID, user)
+
+	return Authorize(user, action, resource)
+}
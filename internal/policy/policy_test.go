@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+// Every subject acts on the same object: project 2 is managed by user 2,
+// the object belongs to user 3 and is assigned to user 4. Subjects that are
+// project members carry their project role.
+var base = Resource{OwnerId: 3, ProjectManagerId: 2, AssigneeId: 4}
+
+type subject struct {
+	user *types.User
+	role types.ProjectRole
+}
+
+var subjects = map[string]subject{
+	"admin":           {user: &types.User{ID: 1, UserRole: types.Admin}},
+	"manager":         {user: &types.User{ID: 9, UserRole: types.Manager}},
+	"project manager": {user: &types.User{ID: 2, UserRole: types.Developer}},
+	"owner":           {user: &types.User{ID: 3, UserRole: types.Developer}},
+	"project owner":   {user: &types.User{ID: 5, UserRole: types.Developer}, role: types.Owner},
+	"maintainer":      {user: &types.User{ID: 6, UserRole: types.Developer}, role: types.Maintainer},
+	"contributor":     {user: &types.User{ID: 7, UserRole: types.Developer}, role: types.Contributor},
+	"viewer":          {user: &types.User{ID: 8, UserRole: types.Developer}, role: types.Viewer},
+	"assignee":        {user: &types.User{ID: 4, UserRole: types.Developer}},
+	"non-member":      {user: &types.User{ID: 10, UserRole: types.Developer}},
+	"nil user":        {},
+}
+
+var (
+	projectAdmins = []string{"admin", "project manager", "project owner", "maintainer"}
+	projectOwners = []string{"admin", "owner", "project manager", "project owner", "maintainer"}
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		action  Action
+		allowed []string
+	}{
+		{ManageUsers, []string{"admin"}},
+		{ViewAudit, []string{"admin"}},
+
+		{CreateTeam, []string{"admin", "manager"}},
+		{ManageTeam, []string{"admin", "owner"}},
+
+		{CreateProject, []string{"admin", "manager"}},
+		{UpdateProject, []string{"admin", "project manager"}},
+		{DeleteProject, []string{"admin", "project manager"}},
+
+		{ManageProjectMembers, projectAdmins},
+		{ManageProjectAdmins, []string{"admin", "project manager", "project owner"}},
+		{ManageWorkflow, projectAdmins},
+		{ManageLabels, projectAdmins},
+		{ManageFields, projectAdmins},
+		{ManageSprints, projectAdmins},
+		{ManageMilestones, projectAdmins},
+
+		{ManageFilter, []string{"admin", "owner"}},
+		{ViewFilter, []string{"admin", "owner", "project owner", "maintainer", "contributor", "viewer"}},
+
+		{CreateTask, projectAdmins},
+		{UpdateTask, append([]string{"assignee"}, projectAdmins...)},
+		{DeleteTask, projectAdmins},
+
+		{CreateComment, append([]string{"contributor", "viewer"}, projectAdmins...)},
+		{UpdateComment, []string{"owner"}},
+		{DeleteComment, projectOwners},
+		{ViewMentions, []string{"admin", "owner"}},
+
+		{UploadAttachment, append([]string{"contributor", "assignee"}, projectAdmins...)},
+		{DeleteAttachment, projectOwners},
+
+		{LogWork, append([]string{"contributor", "assignee"}, projectAdmins...)},
+		{ManageWorklog, projectOwners},
+		{ViewTimeReport, projectOwners},
+	}
+
+	covered := map[Action]bool{}
+
+	for _, test := range tests {
+		covered[test.action] = true
+
+		allowed := map[string]bool{}
+		for _, name := range test.allowed {
+			allowed[name] = true
+		}
+
+		for name, subject := range subjects {
+			resource := base
+			resource.MemberRole = subject.role
+
+			if got := Can(subject.user, test.action, resource); got != allowed[name] {
+				t.Errorf("Can(%s, %q) = %v, want %v", name, test.action, got, allowed[name])
+			}
+		}
+	}
+
+	for action := range rules {
+		if !covered[action] {
+			t.Errorf("action %q is not covered", action)
+		}
+	}
+}
+
+func TestCanUnknownAction(t *testing.T) {
+	if Can(subjects["admin"].user, Action("unknown"), base) {
+		t.Error("an unknown action must be denied")
+	}
+}
+
+func TestCanWithoutOwnership(t *testing.T) {
+	// A user without an id must not match the zero ids of an empty resource
+	user := &types.User{UserRole: types.Developer}
+
+	for _, action := range []Action{ManageTeam, UpdateProject, UpdateComment, UpdateTask} {
+		if Can(user, action, Resource{}) {
+			t.Errorf("Can(%q) on an empty resource = true, want false", action)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	if err := Authorize(subjects["admin"].user, ManageUsers, base); err != nil {
+		t.Errorf("Authorize(admin) = %v, want nil", err)
+	}
+
+	err := Authorize(subjects["viewer"].user, ManageUsers, base)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Authorize(viewer) = %v, want ErrForbidden", err)
+	}
+}
+
+type members map[int]types.ProjectRole
+
+func (m members) GetProjectMember(projectId int, userId int) (*types.ProjectMember, error) {
+	role, ok := m[userId]
+	if !ok {
+		return nil, errors.New("not a member")
+	}
+
+	return &types.ProjectMember{ProjectId: projectId, UserId: userId, ProjectRole: role}, nil
+}
+
+func TestAuthorizeProject(t *testing.T) {
+	project := &types.Project{ID: 1, ManagerId: 2}
+	store := members{6: types.Maintainer, 8: types.Viewer}
+
+	tests := []struct {
+		name    string
+		user    *types.User
+		allowed bool
+	}{
+		{"project manager", &types.User{ID: 2, UserRole: types.Developer}, true},
+		{"maintainer", &types.User{ID: 6, UserRole: types.Developer}, true},
+		{"viewer", &types.User{ID: 8, UserRole: types.Developer}, false},
+		{"non-member", &types.User{ID: 10, UserRole: types.Developer}, false},
+		{"nil user", nil, false},
+	}
+
+	for _, test := range tests {
+		err := AuthorizeProject(store, test.user, ManageSprints, project, Resource{})
+		if (err == nil) != test.allowed {
+			t.Errorf("AuthorizeProject(%s) = %v, want allowed %v", test.name, err, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package attachments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/config"
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/pkg/storage"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Room for the multipart boundaries and headers on top of the file itself
+	multipartOverhead = 1 << 20
+	// Parts larger than this are buffered in temporary files instead of memory
+	multipartMemory   = 8 << 20
+	maxFileNameLength = 255
+)
+
+type Handler struct {
+	store        types.AttachmentStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+	files        storage.Storage
+}
+
+func NewHandler(store types.AttachmentStore, taskStore types.TaskStore, projectStore types.ProjectStore, files storage.Storage) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+		files:        files,
+	}
+}
+
+// Registers the routes under the tasks router, as attachments belong to a task
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/attachments", h.handleListAttachments).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/attachments", h.handleUploadAttachment).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/attachments/{attachmentId}", h.handleDownloadAttachment).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/attachments/{attachmentId}", h.handleDeleteAttachment).Methods(http.MethodDelete)
+}
+
+// @Summary List task attachments
+// @Description Get the metadata of the files attached to a task
+// @Tags Attachments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {array} types.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/attachments [get]
+func (h *Handler) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.store.ListTaskAttachments(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, attachments)
+}
+
+// @Summary Upload attachment
+// @Description Attach a file to a task. The size is limited by ATTACHMENT_MAX_SIZE and the content type, detected from the file itself, must be one of ATTACHMENT_CONTENT_TYPES.
+// @Tags Attachments
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/attachments [post]
+func (h *Handler) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.UploadAttachment, task, nil); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	maxSize := config.Envs.AttachmentMaxSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("file is larger than %d bytes", maxSize))
+			return
+		}
+
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid multipart form: %v", err))
+		return
+	}
+
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("file is required: %v", err))
+		return
+	}
+
+	defer file.Close()
+
+	if header.Size > maxSize {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("file is larger than %d bytes", maxSize))
+		return
+	}
+
+	contentType, err := detectContentType(file)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !slices.Contains(config.Envs.AttachmentContentTypes, contentType) {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type %s is not allowed", contentType))
+		return
+	}
+
+	key, err := storageKey(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.files.Put(r.Context(), key, file, header.Size, contentType); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to store file: %v", err))
+		return
+	}
+
+	uploaderId := auth.GetUserIdFromContext(r.Context())
+
+	err = h.store.CreateAttachment(types.Attachment{
+		TaskId:      task.ID,
+		FileName:    fileName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+		UploaderId:  &uploaderId,
+	})
+
+	if err != nil {
+		if err := h.files.Delete(r.Context(), key); err != nil {
+			log.Println("Failed to remove orphaned attachment", key, err)
+		}
+
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Download attachment
+// @Description Download the content of a file attached to a task
+// @Tags Attachments
+// @Accept  json
+// @Produce  octet-stream
+// @Param id path int true "Task ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/attachments/{attachmentId} [get]
+func (h *Handler) handleDownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	_, attachment, ok := h.getAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	content, err := h.files.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("attachment content is missing"))
+			return
+		}
+
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Println("Failed to send attachment", attachment.ID, err)
+	}
+}
+
+// @Summary Delete attachment
+// @Description Delete a file attached to a task. Allowed for the uploader and for project owners and maintainers.
+// @Tags Attachments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/attachments/{attachmentId} [delete]
+func (h *Handler) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	task, attachment, ok := h.getAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.DeleteAttachment, task, attachment.UploaderId); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteAttachment(attachment.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	storage.DeleteAll(r.Context(), h.files, []string{attachment.StorageKey})
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// Loads the task and the attachment from the path, making sure the attachment belongs to that task
+func (h *Handler) getAttachment(w http.ResponseWriter, r *http.Request) (*types.Task, *types.Attachment, bool) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return nil, nil, false
+	}
+
+	attachmentId, _ := strconv.Atoi(mux.Vars(r)["attachmentId"])
+
+	attachment, err := h.store.GetAttachmentById(attachmentId)
+	if err != nil || attachment.TaskId != task.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get attachment by id: attachment not found"))
+		return nil, nil, false
+	}
+
+	return task, attachment, true
+}
+
+// Checks the action against the uploader, the task's assignee, the project manager and the caller's role in the project
+func (h *Handler) authorize(r *http.Request, action policy.Action, task *types.Task, uploaderId *int) error {
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	resource := policy.Resource{AssigneeId: task.UserId}
+	if uploaderId != nil {
+		resource.OwnerId = *uploaderId
+	}
+
+	return policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), action, project, resource)
+}
+
+// Sniffs the content type from the start of the file rather than trusting
+// the client, and rewinds the file afterwards
+func detectContentType(file io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", err
+	}
+
+	return mediaType, nil
+}
+
+// Objects are stored under random keys, so that client supplied names never reach the storage
+func storageKey(taskId int) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tasks/%d/%s", taskId, hex.EncodeToString(random)), nil
+}
+
+func fileName(name string) string {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if len(name) > maxFileNameLength {
+		name = name[len(name)-maxFileNameLength:]
+	}
+
+	return name
+}
@@ -0,0 +1,129 @@
+package attachments
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoAttachment expects
+const Columns = "id, taskId, fileName, contentType, size, storageKey, uploaderId, createdAt"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListTaskAttachments(taskId int) ([]types.Attachment, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM attachments WHERE taskId = $1 ORDER BY createdAt, id", taskId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachments := []types.Attachment{}
+	for rows.Next() {
+		attachment, err := ScanRowIntoAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, *attachment)
+	}
+
+	return attachments, nil
+}
+
+func (s *Store) GetAttachmentById(attachmentId int) (*types.Attachment, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM attachments WHERE id = $1", attachmentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachment := new(types.Attachment)
+	for rows.Next() {
+		attachment, err = ScanRowIntoAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if attachment.ID == 0 {
+		return nil, fmt.Errorf("attachment not found")
+	}
+
+	return attachment, nil
+}
+
+func (s *Store) CreateAttachment(attachment types.Attachment) error {
+	_, err := s.db.Exec("INSERT INTO attachments (taskId, fileName, contentType, size, storageKey, uploaderId) "+
+		"VALUES ($1, $2, $3, $4, $5, $6)",
+		attachment.TaskId, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.UploaderId)
+
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteAttachment(attachmentId int) error {
+	_, err := s.db.Exec("DELETE FROM attachments WHERE id = $1", attachmentId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	return nil
+}
+
+// Returns the storage keys of the attachments of the tasks the condition on
+// tasks selects. Deleting a task deletes its attachments with it, so stores
+// collect the keys first and the content is removed once they commit.
+func StorageKeys(tx *sql.Tx, condition string, args ...any) ([]string, error) {
+	keys := []string{}
+	err := tx.QueryRow("SELECT ARRAY(SELECT storageKey FROM attachments "+
+		"WHERE taskId IN (SELECT id FROM tasks WHERE "+condition+") ORDER BY id)", args...).Scan(pq.Array(&keys))
+
+	return keys, err
+}
+
+func ScanRowIntoAttachment(rows *sql.Rows) (*types.Attachment, error) {
+	attachment := new(types.Attachment)
+
+	var uploaderId sql.NullInt64
+
+	err := rows.Scan(
+		&attachment.ID,
+		&attachment.TaskId,
+		&attachment.FileName,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.StorageKey,
+		&uploaderId,
+		&attachment.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if uploaderId.Valid {
+		id := int(uploaderId.Int64)
+		attachment.UploaderId = &id
+	}
+
+	return attachment, nil
+}
@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/gorilla/mux"
+)
+
+var (
+	entities = []types.AuditEntity{types.AuditTask, types.AuditProject, types.AuditUser}
+	actions  = []types.AuditAction{types.AuditCreate, types.AuditUpdate, types.AuditDelete}
+)
+
+type Handler struct {
+	store types.AuditStore
+}
+
+func NewHandler(store types.AuditStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.handleListEvents).Methods(http.MethodGet)
+}
+
+// Registers the history route under the tasks router
+func (h *Handler) RegisterTaskRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/history", h.handleTaskHistory).Methods(http.MethodGet)
+}
+
+// @Summary List audit events
+// @Description Get the audit trail of changes to tasks, projects and users. Admin only.
+// @Tags Audit
+// @Accept  json
+// @Produce  json
+// @Param entity_type query string false "task, project or user"
+// @Param entity_id query int false "ID of the changed entity"
+// @Param actor query int false "ID of the user who made the change"
+// @Param action query string false "create, update or delete"
+// @Param after query string false "Changed at or after (YYYY-MM-DD or RFC 3339)"
+// @Param before query string false "Changed before (YYYY-MM-DD or RFC 3339)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "id or created_at, prefix with - for descending"
+// @Success 200 {object} types.Page[types.AuditEvent]
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *Handler) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ViewAudit, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	filter, err := ParseAuditFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := h.store.ListEvents(filter, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, events)
+}
+
+// @Summary Get task history
+// @Description Get the changes made to a task, oldest first, including its creation and deletion
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} types.Page[types.AuditEvent]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/history [get]
+func (h *Handler) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := h.store.ListEvents(types.AuditFilter{EntityType: types.AuditTask, EntityId: taskId}, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, events)
+}
+
+func ParseAuditFilter(query url.Values) (types.AuditFilter, error) {
+	filter := types.AuditFilter{
+		EntityType: types.AuditEntity(query.Get("entity_type")),
+		Action:     types.AuditAction(query.Get("action")),
+	}
+
+	if filter.EntityType != "" && !slices.Contains(entities, filter.EntityType) {
+		return filter, fmt.Errorf("invalid entity_type %q: expected one of %v", filter.EntityType, entities)
+	}
+
+	if filter.Action != "" && !slices.Contains(actions, filter.Action) {
+		return filter, fmt.Errorf("invalid action %q: expected one of %v", filter.Action, actions)
+	}
+
+	for name, target := range map[string]*int{"entity_id": &filter.EntityId, "actor": &filter.ActorId} {
+		if raw := query.Get(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s %q: expected an integer", name, raw)
+			}
+
+			*target = value
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"after": &filter.After, "before": &filter.Before} {
+		if raw := query.Get(name); raw != "" {
+			date, err := parseDate(raw)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %v", name, err)
+			}
+
+			*target = date
+		}
+	}
+
+	return filter, nil
+}
+
+// Accepts either a full RFC 3339 timestamp or a plain date. Events are
+// stored in UTC without a zone, so the result is converted to UTC.
+func parseDate(raw string) (*time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if date, err := time.Parse(layout, raw); err == nil {
+			date = date.UTC()
+			return &date, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", raw)
+}
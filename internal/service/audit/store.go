@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+)
+
+// Columns is the select list ScanRowIntoEvent expects
+const Columns = "id, entityType, entityId, action, actorId, changes, createdAt"
+
+// Keyset lists the fields events can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":         "id",
+	"created_at": "createdAt",
+}
+
+// Fields that change on every write and would only add noise to a diff
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListEvents(filter types.AuditFilter, params types.ListParams) (*types.Page[types.AuditEvent], error) {
+	conditions := []string{}
+	args := []any{}
+
+	add := func(column string, op string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", column, op, len(args)))
+	}
+
+	if filter.EntityType != "" {
+		add("entityType", "=", filter.EntityType)
+	}
+
+	if filter.EntityId != 0 {
+		add("entityId", "=", filter.EntityId)
+	}
+
+	if filter.ActorId != 0 {
+		add("actorId", "=", filter.ActorId)
+	}
+
+	if filter.Action != "" {
+		add("action", "=", filter.Action)
+	}
+
+	if filter.After != nil {
+		add("createdAt", ">=", *filter.After)
+	}
+
+	if filter.Before != nil {
+		add("createdAt", "<", *filter.Before)
+	}
+
+	return db.Paginate(s.db, db.PageQuery{
+		Select: Columns,
+		From:   "audit_events",
+		Where:  strings.Join(conditions, " AND "),
+		Args:   args,
+	}, Keyset, params, ScanRowIntoEvent)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Appends an event to the audit trail. Stores call it inside the transaction
+// that makes the change, so that the event is written if and only if the
+// change is. An actorId of 0 records a change made by the system.
+func Record(db execer, entity types.AuditEntity, entityId int, action types.AuditAction, actorId int, changes map[string]types.FieldChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	var actor any
+	if actorId != 0 {
+		actor = actorId
+	}
+
+	_, err = db.Exec("INSERT INTO audit_events (entityType, entityId, action, actorId, changes) VALUES ($1, $2, $3, $4, $5)",
+		entity, entityId, action, actor, data)
+
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+// Compares two versions of an entity field by field, using their JSON
+// representation. Before is nil for a creation and after is nil for a deletion.
+func Diff(before any, after any) (map[string]types.FieldChange, error) {
+	oldFields, err := fieldsOf(before)
+	if err != nil {
+		return nil, err
+	}
+
+	newFields, err := fieldsOf(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]types.FieldChange{}
+	for name, value := range oldFields {
+		if !ignoredFields[name] && !reflect.DeepEqual(value, newFields[name]) {
+			changes[name] = types.FieldChange{Old: value, New: newFields[name]}
+		}
+	}
+
+	for name, value := range newFields {
+		if _, ok := oldFields[name]; !ok && !ignoredFields[name] {
+			changes[name] = types.FieldChange{Old: nil, New: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func fieldsOf(entity any) (map[string]any, error) {
+	fields := map[string]any{}
+	if value := reflect.ValueOf(entity); !value.IsValid() || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func ScanRowIntoEvent(rows *sql.Rows) (*types.AuditEvent, error) {
+	event := new(types.AuditEvent)
+
+	var actorId sql.NullInt64
+	var changes []byte
+
+	err := rows.Scan(
+		&event.ID,
+		&event.EntityType,
+		&event.EntityId,
+		&event.Action,
+		&actorId,
+		&changes,
+		&event.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if actorId.Valid {
+		id := int(actorId.Int64)
+		event.ActorId = &id
+	}
+
+	if err := json.Unmarshal(changes, &event.Changes); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
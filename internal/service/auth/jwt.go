@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4lerman/pm_service/internal/config"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const UserKey contextKey = "user"
+
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+type Claims struct {
+	TokenType TokenType `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+func CreateJWT(secret []byte, userId int, tokenType TokenType, expiration time.Duration) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userId),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+		},
+	})
+
+	return token.SignedString(secret)
+}
+
+// Validates the token signature, expiry and type and returns the user id it was issued for
+func ParseJWT(secret []byte, tokenString string, tokenType TokenType) (int, error) {
+	claims := new(Claims)
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return 0, err
+	}
+
+	if claims.TokenType != tokenType {
+		return 0, fmt.Errorf("unexpected token type: %s", claims.TokenType)
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("invalid token subject")
+	}
+
+	return userId, nil
+}
+
+func WithJWTAuth(store types.UserStore) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := getTokenFromRequest(r)
+			if tokenString == "" {
+				utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("missing bearer token"))
+				return
+			}
+
+			userId, err := ParseJWT([]byte(config.Envs.JWTSecret), tokenString, AccessToken)
+			if err != nil {
+				utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token: %v", err))
+				return
+			}
+
+			user, err := store.GetUserById(userId)
+			if err != nil {
+				utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token: %v", err))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func GetUserFromContext(ctx context.Context) *types.User {
+	user, ok := ctx.Value(UserKey).(*types.User)
+	if !ok {
+		return nil
+	}
+
+	return user
+}
+
+// Returns the id of the authenticated user, or 0 outside an authenticated request
+func GetUserIdFromContext(ctx context.Context) int {
+	if user := GetUserFromContext(ctx); user != nil {
+		return user.ID
+	}
+
+	return 0
+}
+
+func getTokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
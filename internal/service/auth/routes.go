@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/4lerman/pm_service/internal/config"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store     types.CredentialStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.CredentialStore, userStore types.UserStore) *Handler {
+	return &Handler{
+		store:     store,
+		userStore: userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost)
+	router.HandleFunc("/refresh", h.handleRefresh).Methods(http.MethodPost)
+}
+
+// @Summary Log in
+// @Description Exchange user credentials for an access and refresh token pair
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param credentials body types.LoginPayload true "User credentials"
+// @Success 200 {object} types.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/login [post]
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var payload types.LoginPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	credentials, err := h.store.GetCredentialsByEmail(payload.Email)
+	if err != nil || !ComparePasswords(credentials.PasswordHash, payload.Password) {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid email or password"))
+		return
+	}
+
+	tokens, err := issueTokens(credentials.UserId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token pair
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param token body types.RefreshPayload true "Refresh token"
+// @Success 200 {object} types.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var payload types.RefreshPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	userId, err := ParseJWT([]byte(config.Envs.JWTSecret), payload.RefreshToken, RefreshToken)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token: %v", err))
+		return
+	}
+
+	if _, err := h.userStore.GetUserById(userId); err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid token: %v", err))
+		return
+	}
+
+	tokens, err := issueTokens(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+func issueTokens(userId int) (*types.TokenResponse, error) {
+	secret := []byte(config.Envs.JWTSecret)
+	accessExp := time.Second * time.Duration(config.Envs.JWTAccessExpirationInSeconds)
+	refreshExp := time.Second * time.Duration(config.Envs.JWTRefreshExpirationInSeconds)
+
+	accessToken, err := CreateJWT(secret, userId, AccessToken, accessExp)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := CreateJWT(secret, userId, RefreshToken, refreshExp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    config.Envs.JWTAccessExpirationInSeconds,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetCredentialsByEmail(email string) (*types.Credentials, error) {
+	rows, err := s.db.Query("SELECT c.userId, c.passwordHash FROM credentials c "+
+		"JOIN users u ON u.id = c.userId WHERE u.email = $1", email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credentials := new(types.Credentials)
+	for rows.Next() {
+		if err := rows.Scan(&credentials.UserId, &credentials.PasswordHash); err != nil {
+			return nil, err
+		}
+	}
+
+	if credentials.UserId == 0 {
+		return nil, fmt.Errorf("credentials not found")
+	}
+
+	return credentials, nil
+}
+
+// Sets the password of a user that has none yet
+func (s *Store) CreateCredentials(userId int, passwordHash string) error {
+	_, err := s.db.Exec("INSERT INTO credentials (userId, passwordHash) VALUES ($1, $2) "+
+		"ON CONFLICT (userId) DO NOTHING", userId, passwordHash)
+
+	return err
+}
@@ -0,0 +1,79 @@
+package board
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.BoardStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.BoardStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the board route under the projects router, as every project has one board
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/board", h.handleGetBoard).Methods(http.MethodGet)
+}
+
+// @Summary Get project board
+// @Description Get the Kanban board of a project: a column per workflow state in workflow order with its WIP limit and tasks in rank order.
+// @Description The count of a column covers all of the project's tasks in it, also when the board is narrowed down to a sprint.
+// @Tags Board
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprint query int false "Only show the tasks of this sprint"
+// @Success 200 {object} types.Board
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/board [get]
+func (h *Handler) handleGetBoard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	var sprintId *int
+	if sprint := r.URL.Query().Get("sprint"); sprint != "" {
+		id, err := strconv.Atoi(sprint)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid sprint %q", sprint))
+			return
+		}
+
+		sprintId = &id
+	}
+
+	board, err := h.store.GetBoard(project.ID, sprintId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, board)
+}
@@ -0,0 +1,79 @@
+package board
+
+import (
+	"database/sql"
+
+	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the board of a project with a column per workflow state in
+// workflow order and the tasks of each column in rank order. When sprintId
+// is set only the tasks of that sprint are listed.
+func (s *Store) GetBoard(projectId int, sprintId *int) (*types.Board, error) {
+	board := &types.Board{ProjectId: projectId, SprintId: sprintId, Columns: []types.BoardColumn{}}
+
+	rows, err := s.db.Query("SELECT workflow_states.name, workflow_states.category, workflow_states.wipLimit, "+
+		"(SELECT COUNT(*) FROM tasks WHERE tasks.projectId = workflow_states.projectId AND tasks.status = workflow_states.name) "+
+		"FROM workflow_states WHERE projectId = $1 ORDER BY position", projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := map[string]int{}
+	for rows.Next() {
+		column := types.BoardColumn{Tasks: []types.Task{}}
+
+		var wipLimit sql.NullInt64
+		if err := rows.Scan(&column.Status, &column.Category, &wipLimit, &column.Count); err != nil {
+			return nil, err
+		}
+
+		if wipLimit.Valid {
+			limit := int(wipLimit.Int64)
+			column.WipLimit = &limit
+		}
+
+		columns[column.Status] = len(board.Columns)
+		board.Columns = append(board.Columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	taskRows, err := s.db.Query("SELECT "+tasks.Columns+" FROM tasks WHERE projectId = $1 AND ($2::int IS NULL OR sprintId = $2) "+
+		"ORDER BY rank, id", projectId, sprintId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer taskRows.Close()
+
+	for taskRows.Next() {
+		task, err := tasks.ScanRowIntoTask(taskRows)
+		if err != nil {
+			return nil, err
+		}
+
+		if i, ok := columns[task.Status]; ok {
+			board.Columns[i].Tasks = append(board.Columns[i].Tasks, *task)
+		}
+	}
+
+	return board, nil
+}
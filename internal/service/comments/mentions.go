@@ -0,0 +1,27 @@
+package comments
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A mention is an email address prefixed with @, e.g. "thanks @jane@example.com".
+// The address must not be preceded by a word character, so plain addresses
+// in the text are not taken as mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Returns the distinct, lower-cased emails mentioned in a comment body
+func parseMentions(body string) []string {
+	emails := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(match[1])
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+
+	return emails
+}
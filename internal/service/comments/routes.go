@@ -0,0 +1,338 @@
+package comments
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.CommentStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.CommentStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the routes under the tasks router, as comments belong to a task
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/comments", h.handleListComments).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/comments", h.handleCreateComment).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/comments/{commentId}", h.handleGetCommentById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/comments/{commentId}", h.handleUpdateComment).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/comments/{commentId}", h.handleDeleteComment).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/comments/{commentId}/history", h.handleCommentHistory).Methods(http.MethodGet)
+}
+
+// Registers the mentions route under the users router
+func (h *Handler) RegisterUserRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/mentions", h.handleListMentions).Methods(http.MethodGet)
+}
+
+// @Summary List task comments
+// @Description Get the comments on a task as threads, oldest first, with replies nested under the comment they answer
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {array} types.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments [get]
+func (h *Handler) handleListComments(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	comments, err := h.store.ListTaskComments(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, comments)
+}
+
+// @Summary Comment on a task
+// @Description Add a comment to a task, or a reply when parent_id is set. The author is the authenticated user. Users mentioned as @email in the body are linked to the comment.
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param comment body types.CreateCommentPayload true "Comment details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments [post]
+func (h *Handler) handleCreateComment(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.CreateComment, task, nil); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.CreateCommentPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if payload.ParentId != nil {
+		parent, err := h.store.GetCommentById(*payload.ParentId)
+		if err != nil || parent.TaskId != task.ID {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("parent comment %d is not a comment on task %d", *payload.ParentId, task.ID))
+			return
+		}
+	}
+
+	authorId := auth.GetUserIdFromContext(r.Context())
+
+	err := h.store.CreateComment(types.Comment{
+		TaskId:   task.ID,
+		ParentId: payload.ParentId,
+		AuthorId: &authorId,
+		Body:     payload.Body,
+	})
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get comment by ID
+// @Description Get a single comment on a task
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param commentId path int true "Comment ID"
+// @Success 200 {object} types.Comment
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments/{commentId} [get]
+func (h *Handler) handleGetCommentById(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := h.getComment(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, comment)
+}
+
+// @Summary Edit comment
+// @Description Replace the body of a comment. Only the author can edit a comment; the previous body is kept in its history.
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param commentId path int true "Comment ID"
+// @Param comment body types.UpdateCommentPayload true "New body"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments/{commentId} [put]
+func (h *Handler) handleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	task, comment, ok := h.getComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.UpdateComment, task, comment.AuthorId); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.UpdateCommentPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.store.UpdateComment(comment.ID, payload.Body); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete comment
+// @Description Delete a comment and its replies. Allowed for the author and for project owners and maintainers.
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param commentId path int true "Comment ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments/{commentId} [delete]
+func (h *Handler) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	task, comment, ok := h.getComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.DeleteComment, task, comment.AuthorId); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteComment(comment.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary Get comment edit history
+// @Description Get the previous bodies of an edited comment, oldest first
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param commentId path int true "Comment ID"
+// @Success 200 {array} types.CommentRevision
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/comments/{commentId}/history [get]
+func (h *Handler) handleCommentHistory(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := h.getComment(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.store.ListCommentRevisions(comment.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, revisions)
+}
+
+// @Summary List user mentions
+// @Description Get the comments the user is mentioned in
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -created_at"
+// @Success 200 {object} types.Page[types.Comment]
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/mentions [get]
+func (h *Handler) handleListMentions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	userId, _ := strconv.Atoi(id)
+
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ViewMentions, policy.Resource{
+		OwnerId: userId,
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	comments, err := h.store.ListMentions(userId, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, comments)
+}
+
+// Loads the task and the comment from the path, making sure the comment is on that task
+func (h *Handler) getComment(w http.ResponseWriter, r *http.Request) (*types.Task, *types.Comment, bool) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return nil, nil, false
+	}
+
+	commentId, _ := strconv.Atoi(mux.Vars(r)["commentId"])
+
+	comment, err := h.store.GetCommentById(commentId)
+	if err != nil || comment.TaskId != task.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get comment by id: comment not found"))
+		return nil, nil, false
+	}
+
+	return task, comment, true
+}
+
+// Checks the action against the comment's author, the project manager and the caller's role in the project
+func (h *Handler) authorize(r *http.Request, action policy.Action, task *types.Task, authorId *int) error {
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	resource := policy.Resource{}
+	if authorId != nil {
+		resource.OwnerId = *authorId
+	}
+
+	return policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), action, project, resource)
+}
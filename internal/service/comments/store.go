@@ -0,0 +1,262 @@
+package comments
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoComment expects
+const Columns = "id, taskId, parentId, authorId, body, createdAt, updatedAt, " +
+	"ARRAY(SELECT userId FROM comment_mentions WHERE commentId = comments.id ORDER BY userId)"
+
+// Keyset lists the fields comments can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":         "id",
+	"created_at": "createdAt",
+	"updated_at": "updatedAt",
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the comments of a task as threads: top-level comments in the order
+// they were written, each with its replies nested below it
+func (s *Store) ListTaskComments(taskId int) ([]types.Comment, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM comments WHERE taskId = $1 ORDER BY createdAt, id", taskId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*types.Comment{}
+	for rows.Next() {
+		comment, err := ScanRowIntoComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return buildThreads(comments), nil
+}
+
+func (s *Store) GetCommentById(commentId int) (*types.Comment, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM comments WHERE id = $1", commentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comment := new(types.Comment)
+	for rows.Next() {
+		comment, err = ScanRowIntoComment(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if comment.ID == 0 {
+		return nil, fmt.Errorf("comment not found")
+	}
+
+	return comment, nil
+}
+
+func (s *Store) CreateComment(comment types.Comment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var commentId int
+	err = tx.QueryRow("INSERT INTO comments (taskId, parentId, authorId, body) VALUES ($1, $2, $3, $4) RETURNING id",
+		comment.TaskId, comment.ParentId, comment.AuthorId, comment.Body).Scan(&commentId)
+
+	if err != nil {
+		return err
+	}
+
+	if err := saveMentions(tx, commentId, comment.Body); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replaces the body of a comment, keeping the previous one as a revision
+func (s *Store) UpdateComment(commentId int, body string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO comment_revisions (commentId, body) "+
+		"SELECT id, body FROM comments WHERE id = $1 AND body <> $2", commentId, body)
+
+	if err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE comments SET body = $1, updatedAt = NOW() WHERE id = $2", body, commentId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	if err := saveMentions(tx, commentId, body); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a comment together with its replies
+func (s *Store) DeleteComment(commentId int) error {
+	_, err := s.db.Exec("DELETE FROM comments WHERE id = $1", commentId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) ListCommentRevisions(commentId int) ([]types.CommentRevision, error) {
+	rows, err := s.db.Query("SELECT id, commentId, body, editedAt FROM comment_revisions "+
+		"WHERE commentId = $1 ORDER BY editedAt, id", commentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []types.CommentRevision{}
+	for rows.Next() {
+		revision := types.CommentRevision{}
+		if err := rows.Scan(&revision.ID, &revision.CommentId, &revision.Body, &revision.EditedAt); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+// Returns the comments the user is mentioned in
+func (s *Store) ListMentions(userId int, params types.ListParams) (*types.Page[types.Comment], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: Columns,
+		From:   "comments",
+		Where:  "id IN (SELECT commentId FROM comment_mentions WHERE userId = $1)",
+		Args:   []any{userId},
+	}, Keyset, params, ScanRowIntoComment)
+}
+
+// Links the comment to the users mentioned in its body. Mentions of unknown
+// emails are ignored.
+func saveMentions(tx *sql.Tx, commentId int, body string) error {
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE commentId = $1", commentId); err != nil {
+		return err
+	}
+
+	emails := parseMentions(body)
+	if len(emails) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec("INSERT INTO comment_mentions (commentId, userId) "+
+		"SELECT $1, id FROM users WHERE lower(email) = ANY($2) ON CONFLICT DO NOTHING",
+		commentId, pq.Array(emails))
+
+	return err
+}
+
+func buildThreads(comments []*types.Comment) []types.Comment {
+	children := map[int][]*types.Comment{}
+	roots := []*types.Comment{}
+
+	for _, comment := range comments {
+		if comment.ParentId == nil {
+			roots = append(roots, comment)
+		} else {
+			children[*comment.ParentId] = append(children[*comment.ParentId], comment)
+		}
+	}
+
+	var nest func(comment *types.Comment) types.Comment
+	nest = func(comment *types.Comment) types.Comment {
+		for _, child := range children[comment.ID] {
+			comment.Replies = append(comment.Replies, nest(child))
+		}
+
+		return *comment
+	}
+
+	threads := []types.Comment{}
+	for _, root := range roots {
+		threads = append(threads, nest(root))
+	}
+
+	return threads
+}
+
+func ScanRowIntoComment(rows *sql.Rows) (*types.Comment, error) {
+	comment := new(types.Comment)
+
+	var parentId, authorId sql.NullInt64
+	var mentions []int64
+
+	err := rows.Scan(
+		&comment.ID,
+		&comment.TaskId,
+		&parentId,
+		&authorId,
+		&comment.Body,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		pq.Array(&mentions),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parentId.Valid {
+		id := int(parentId.Int64)
+		comment.ParentId = &id
+	}
+
+	if authorId.Valid {
+		id := int(authorId.Int64)
+		comment.AuthorId = &id
+	}
+
+	comment.Mentions = make([]int, len(mentions))
+	for i, userId := range mentions {
+		comment.Mentions[i] = int(userId)
+	}
+
+	return comment, nil
+}
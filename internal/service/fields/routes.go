@@ -0,0 +1,353 @@
+package fields
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+// Field names appear in query parameters and sort keys, e.g. field.story_points
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Handler struct {
+	store        types.CustomFieldStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.CustomFieldStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the field definition routes under the projects router
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/fields", h.handleListFields).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/fields", h.handleCreateField).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/fields/{fieldId}", h.handleUpdateField).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/fields/{fieldId}", h.handleDeleteField).Methods(http.MethodDelete)
+}
+
+// Registers the route that sets field values under the tasks router
+func (h *Handler) RegisterTaskRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/fields", h.handleSetFieldValues).Methods(http.MethodPut)
+}
+
+// @Summary List custom fields
+// @Description Get the custom fields a project defines for its tasks
+// @Tags Custom fields
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.CustomField
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/fields [get]
+func (h *Handler) handleListFields(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	fields, err := h.store.ListProjectFields(project.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, fields)
+}
+
+// @Summary Create a custom field
+// @Description Define a custom field for the project's tasks. Types are text, number, date, enum and user; enum fields list their allowed values in options.
+// @Description Names are lowercase letters, digits and underscores, unique within the project.
+// @Tags Custom fields
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param field body types.CustomFieldPayload true "Field details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/fields [post]
+func (h *Handler) handleCreateField(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageFields, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateField(types.CustomField{
+		ProjectId: project.ID,
+		Name:      payload.Name,
+		Type:      payload.Type,
+		Options:   payload.Options,
+	})
+
+	if err != nil {
+		utils.WriteError(w, fieldErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Update a custom field
+// @Description Rename a custom field or change the options of an enum field. The type of a field cannot be changed, and options that tasks still use cannot be removed.
+// @Tags Custom fields
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param fieldId path int true "Field ID"
+// @Param field body types.CustomFieldPayload true "Field details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/fields/{fieldId} [put]
+func (h *Handler) handleUpdateField(w http.ResponseWriter, r *http.Request) {
+	project, field, ok := h.getField(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageFields, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	if payload.Type != field.Type {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("the type of field %s cannot be changed from %s", field.Name, field.Type))
+		return
+	}
+
+	err := h.store.UpdateField(field.ID, types.CustomField{
+		Name:    payload.Name,
+		Options: payload.Options,
+	})
+
+	if err != nil {
+		utils.WriteError(w, fieldErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a custom field
+// @Description Delete a custom field together with its values on every task
+// @Tags Custom fields
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param fieldId path int true "Field ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/fields/{fieldId} [delete]
+func (h *Handler) handleDeleteField(w http.ResponseWriter, r *http.Request) {
+	project, field, ok := h.getField(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageFields, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteField(field.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary Set custom field values
+// @Description Set the values of custom fields on a task, keyed by field name, e.g. {"story_points": 5, "customer": "ACME", "due": null}.
+// @Description Numbers are JSON numbers, dates YYYY-MM-DD strings, users ids; null clears a value. Fields that are left out keep their value.
+// @Tags Custom fields
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param values body map[string]any true "Values by field name"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/fields [put]
+func (h *Handler) handleSetFieldValues(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.UpdateTask, project, policy.Resource{AssigneeId: task.UserId}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload := map[string]any{}
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	byName := map[string]types.CustomField{}
+	for _, field := range project.CustomFields {
+		byName[field.Name] = field
+	}
+
+	values := map[int]any{}
+	for name, value := range payload {
+		field, ok := byName[name]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("project %d has no custom field %q", project.ID, name))
+			return
+		}
+
+		if values[field.ID], err = ConvertValue(field, value); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := h.taskStore.SetFieldValues(task.ID, values, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, fieldErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request) (*types.Project, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return nil, false
+	}
+
+	return project, true
+}
+
+// Loads the project and the field from the path, making sure the field belongs to that project
+func (h *Handler) getField(w http.ResponseWriter, r *http.Request) (*types.Project, *types.CustomField, bool) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	fieldId, _ := strconv.Atoi(mux.Vars(r)["fieldId"])
+
+	field, err := h.store.GetFieldById(fieldId)
+	if err != nil || field.ProjectId != project.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get custom field by id: custom field not found"))
+		return nil, nil, false
+	}
+
+	return project, field, true
+}
+
+// Validates the payload; only enum fields have options, and they need at least one
+func parsePayload(w http.ResponseWriter, r *http.Request) (*types.CustomFieldPayload, bool) {
+	var payload types.CustomFieldPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	if !namePattern.MatchString(payload.Name) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid field name %q: use lowercase letters, digits and underscores", payload.Name))
+		return nil, false
+	}
+
+	if payload.Type == types.FieldEnum && len(payload.Options) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("enum fields need at least one option"))
+		return nil, false
+	}
+
+	if payload.Type != types.FieldEnum && len(payload.Options) > 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("only enum fields have options"))
+		return nil, false
+	}
+
+	if payload.Options == nil {
+		payload.Options = []string{}
+	}
+
+	return &payload, true
+}
+
+func fieldErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrInvalidFieldValue):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrFieldExists), errors.Is(err, types.ErrOptionInUse):
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
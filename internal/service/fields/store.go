@@ -0,0 +1,203 @@
+package fields
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoField expects
+const Columns = "id, projectId, name, fieldType, options"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListProjectFields(projectId int) ([]types.CustomField, error) {
+	return ListProjectFields(s.db, projectId)
+}
+
+func (s *Store) GetFieldById(fieldId int) (*types.CustomField, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM custom_fields WHERE id = $1", fieldId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	field := new(types.CustomField)
+	for rows.Next() {
+		field, err = ScanRowIntoField(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if field.ID == 0 {
+		return nil, fmt.Errorf("custom field not found")
+	}
+
+	return field, nil
+}
+
+func (s *Store) CreateField(field types.CustomField) error {
+	_, err := s.db.Exec("INSERT INTO custom_fields (projectId, name, fieldType, options) VALUES ($1, $2, $3, $4)",
+		field.ProjectId, field.Name, field.Type, pq.Array(field.Options))
+
+	if err != nil {
+		return fmt.Errorf("failed to create custom field: %w", uniqueViolation(err))
+	}
+
+	return nil
+}
+
+// Renames a field or changes its options. Options of an enum field cannot
+// be removed while tasks still have them as their value.
+func (s *Store) UpdateField(fieldId int, field types.CustomField) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var inUse []string
+	err = tx.QueryRow("SELECT ARRAY(SELECT DISTINCT textValue FROM task_field_values "+
+		"WHERE fieldId = $1 AND NOT textValue = ANY($2) ORDER BY textValue) "+
+		"FROM custom_fields WHERE id = $1 AND fieldType = 'enum' FOR UPDATE", fieldId, pq.Array(field.Options)).Scan(pq.Array(&inUse))
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to update custom field: %w", err)
+	}
+
+	if len(inUse) > 0 {
+		return fmt.Errorf("%w: tasks still have %v", types.ErrOptionInUse, inUse)
+	}
+
+	_, err = tx.Exec("UPDATE custom_fields SET name = $1, options = $2 WHERE id = $3", field.Name, pq.Array(field.Options), fieldId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update custom field: %w", uniqueViolation(err))
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a field together with its values on every task
+func (s *Store) DeleteField(fieldId int) error {
+	_, err := s.db.Exec("DELETE FROM custom_fields WHERE id = $1", fieldId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete custom field: %w", err)
+	}
+
+	return nil
+}
+
+// Sets the values of a task's custom fields in one go, leaving the fields
+// that are not mentioned as they are. The values are part of the task, so the
+// task store runs this within a transaction that records the change.
+func WriteValues(tx *sql.Tx, taskId int, values map[int]any) error {
+	var err error
+	for fieldId, value := range values {
+		if value == nil {
+			_, err = tx.Exec("DELETE FROM task_field_values WHERE taskId = $1 AND fieldId = $2", taskId, fieldId)
+		} else {
+			columns := make([]any, 4)
+			switch value := value.(type) {
+			case string:
+				columns[0] = value
+			case float64:
+				columns[1] = value
+			case types.Date:
+				columns[2] = value
+			case int:
+				columns[3] = value
+			default:
+				return fmt.Errorf("unsupported custom field value %T", value)
+			}
+
+			_, err = tx.Exec("INSERT INTO task_field_values (taskId, fieldId, textValue, numberValue, dateValue, userValue) "+
+				"VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (taskId, fieldId) DO UPDATE SET "+
+				"textValue = EXCLUDED.textValue, numberValue = EXCLUDED.numberValue, "+
+				"dateValue = EXCLUDED.dateValue, userValue = EXCLUDED.userValue",
+				append([]any{taskId, fieldId}, columns...)...)
+		}
+
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return fmt.Errorf("%w: user %v does not exist", types.ErrInvalidFieldValue, value)
+			}
+
+			return fmt.Errorf("failed to set custom field values: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Returns the custom fields a project defines, in the order they were added
+func ListProjectFields(db querier, projectId int) ([]types.CustomField, error) {
+	rows, err := db.Query("SELECT "+Columns+" FROM custom_fields WHERE projectId = $1 ORDER BY id", projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	fields := []types.CustomField{}
+	for rows.Next() {
+		field, err := ScanRowIntoField(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, *field)
+	}
+
+	return fields, nil
+}
+
+// Field names are unique within a project
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.ErrFieldExists
+	}
+
+	return err
+}
+
+func ScanRowIntoField(rows *sql.Rows) (*types.CustomField, error) {
+	field := new(types.CustomField)
+
+	err := rows.Scan(
+		&field.ID,
+		&field.ProjectId,
+		&field.Name,
+		&field.Type,
+		pq.Array(&field.Options),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
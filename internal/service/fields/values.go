@@ -0,0 +1,130 @@
+package fields
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+const maxTextLength = 1000
+
+// TaskColumn is an object of the custom field values of a task, keyed by
+// field name. Only fields of the task's own project are included.
+const TaskColumn = "COALESCE((SELECT jsonb_object_agg(custom_fields.name, COALESCE(" +
+	"to_jsonb(task_field_values.numberValue), to_jsonb(task_field_values.dateValue), " +
+	"to_jsonb(task_field_values.userValue), to_jsonb(task_field_values.textValue))) " +
+	"FROM task_field_values JOIN custom_fields ON custom_fields.id = task_field_values.fieldId " +
+	"WHERE task_field_values.taskId = tasks.id AND custom_fields.projectId = tasks.projectId), '{}')"
+
+// ProjectColumn is an array of the custom fields a project defines
+const ProjectColumn = "COALESCE((SELECT json_agg(json_build_object(" +
+	"'id', id, 'project_id', projectId, 'name', name, 'type', fieldType, 'options', options) ORDER BY id) " +
+	"FROM custom_fields WHERE custom_fields.projectId = projects.id), '[]')"
+
+// Returns the value of the field on the task of the current tasks row, for
+// use in predicates and sorting
+func ValueExpression(field types.CustomField) string {
+	return fmt.Sprintf("(SELECT %s FROM task_field_values WHERE task_field_values.taskId = tasks.id AND task_field_values.fieldId = %d)",
+		valueColumn(field.Type), field.ID)
+}
+
+func valueColumn(fieldType types.CustomFieldType) string {
+	switch fieldType {
+	case types.FieldNumber:
+		return "numberValue"
+	case types.FieldDate:
+		return "dateValue"
+	case types.FieldUser:
+		return "userValue"
+	}
+
+	return "textValue"
+}
+
+// Checks a value from a JSON body against the field's type and converts it
+// to what is stored: a string, float64, types.Date or user id. Nil stays nil.
+func ConvertValue(field types.CustomField, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case types.FieldNumber:
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+
+	case types.FieldUser:
+		if number, ok := value.(float64); ok && number == math.Trunc(number) && number > 0 && number <= math.MaxInt32 {
+			return int(number), nil
+		}
+
+	default:
+		if text, ok := value.(string); ok {
+			return ParseValue(field, text)
+		}
+	}
+
+	return nil, invalid(field)
+}
+
+// Converts a value given as text, e.g. in a query string, by the field's type
+func ParseValue(field types.CustomField, raw string) (any, error) {
+	switch field.Type {
+	case types.FieldText:
+		if utf8.RuneCountInString(raw) > maxTextLength {
+			return nil, fmt.Errorf("%w: %s is longer than %d characters", types.ErrInvalidFieldValue, field.Name, maxTextLength)
+		}
+
+		return raw, nil
+
+	case types.FieldNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, invalid(field)
+		}
+
+		return number, nil
+
+	case types.FieldDate:
+		date, err := types.ParseDate(raw)
+		if err != nil {
+			return nil, invalid(field)
+		}
+
+		return date, nil
+
+	case types.FieldEnum:
+		if !slices.Contains(field.Options, raw) {
+			return nil, fmt.Errorf("%w: %s must be one of %v", types.ErrInvalidFieldValue, field.Name, field.Options)
+		}
+
+		return raw, nil
+
+	case types.FieldUser:
+		userId, err := strconv.Atoi(raw)
+		if err != nil || userId <= 0 {
+			return nil, invalid(field)
+		}
+
+		return userId, nil
+	}
+
+	return nil, invalid(field)
+}
+
+func invalid(field types.CustomField) error {
+	expected := map[types.CustomFieldType]string{
+		types.FieldText:   "a string",
+		types.FieldNumber: "a number",
+		types.FieldDate:   "a date as YYYY-MM-DD",
+		types.FieldEnum:   "one of its options",
+		types.FieldUser:   "a user id",
+	}
+
+	return fmt.Errorf("%w: %s expects %s", types.ErrInvalidFieldValue, field.Name, expected[field.Type])
+}
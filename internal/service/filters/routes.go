@@ -0,0 +1,458 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/jql"
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.FilterStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.FilterStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the routes under the users router, as filters belong to a user
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/filters", h.handleListFilters).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/filters", h.handleCreateFilter).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/filters/{filterId}", h.handleGetFilterById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/filters/{filterId}", h.handleUpdateFilter).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/filters/{filterId}", h.handleDeleteFilter).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/filters/{filterId}/tasks", h.handleExecuteFilter).Methods(http.MethodGet)
+}
+
+// Registers the read-only routes of the filters shared with a project under the projects router
+func (h *Handler) RegisterProjectRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/filters", h.handleListProjectFilters).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/filters/{filterId}", h.handleGetProjectFilter).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/filters/{filterId}/tasks", h.handleExecuteProjectFilter).Methods(http.MethodGet)
+}
+
+// @Summary List saved filters
+// @Description Get the user's own filters and the filters shared with projects they are a member of. Shared filters of other users are read through /projects/{id}/filters.
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {array} types.SavedFilter
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters [get]
+func (h *Handler) handleListFilters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	userId, _ := strconv.Atoi(id)
+
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ManageFilter, policy.Resource{
+		OwnerId: userId,
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	filters, err := h.store.ListUserFilters(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, filters)
+}
+
+// @Summary Create a saved filter
+// @Description Save a task query under a name, optionally sharing it with a project
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param filter body types.SavedFilterPayload true "Filter details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]any
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters [post]
+func (h *Handler) handleCreateFilter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	userId, _ := strconv.Atoi(id)
+
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ManageFilter, policy.Resource{
+		OwnerId: userId,
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := h.parsePayload(w, r, userId)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateFilter(types.SavedFilter{
+		UserId:    userId,
+		ProjectId: payload.ProjectId,
+		Name:      payload.Name,
+		Query:     payload.Query,
+	})
+
+	if err != nil {
+		utils.WriteError(w, filterErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get saved filter by ID
+// @Description Get a saved filter owned by the user
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param filterId path int true "Filter ID"
+// @Success 200 {object} types.SavedFilter
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters/{filterId} [get]
+func (h *Handler) handleGetFilterById(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getFilter(w, r, policy.ViewFilter)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, filter)
+}
+
+// @Summary Update saved filter
+// @Description Update the name, query or sharing of a saved filter
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param filterId path int true "Filter ID"
+// @Param filter body types.SavedFilterPayload true "Filter details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]any
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters/{filterId} [put]
+func (h *Handler) handleUpdateFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getFilter(w, r, policy.ManageFilter)
+	if !ok {
+		return
+	}
+
+	payload, ok := h.parsePayload(w, r, filter.UserId)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateFilter(filter.ID, types.SavedFilter{
+		ProjectId: payload.ProjectId,
+		Name:      payload.Name,
+		Query:     payload.Query,
+	})
+
+	if err != nil {
+		utils.WriteError(w, filterErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete saved filter
+// @Description Delete a saved filter by its ID
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param filterId path int true "Filter ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters/{filterId} [delete]
+func (h *Handler) handleDeleteFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getFilter(w, r, policy.ManageFilter)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteFilter(filter.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary Execute saved filter
+// @Description Run a saved filter owned by the user and return the matching tasks. currentUser() in the query is the caller.
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param filterId path int true "Filter ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]any
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/filters/{filterId}/tasks [get]
+func (h *Handler) handleExecuteFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getFilter(w, r, policy.ViewFilter)
+	if !ok {
+		return
+	}
+
+	h.execute(w, r, filter)
+}
+
+// @Summary List project filters
+// @Description Get the filters shared with the project
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.SavedFilter
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/filters [get]
+func (h *Handler) handleListProjectFilters(w http.ResponseWriter, r *http.Request) {
+	projectId, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	user := auth.GetUserFromContext(r.Context())
+	if err := policy.Authorize(user, policy.ViewFilter, policy.Resource{
+		MemberRole: policy.ProjectRole(h.projectStore, projectId, user),
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	filters, err := h.store.ListProjectFilters(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, filters)
+}
+
+// @Summary Get project filter by ID
+// @Description Get a saved filter shared with the project
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param filterId path int true "Filter ID"
+// @Success 200 {object} types.SavedFilter
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/filters/{filterId} [get]
+func (h *Handler) handleGetProjectFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getProjectFilter(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, filter)
+}
+
+// @Summary Execute project filter
+// @Description Run a saved filter shared with the project and return the matching tasks. currentUser() in the query is the caller.
+// @Tags Filters
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param filterId path int true "Filter ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]any
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/filters/{filterId}/tasks [get]
+func (h *Handler) handleExecuteProjectFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.getProjectFilter(w, r)
+	if !ok {
+		return
+	}
+
+	h.execute(w, r, filter)
+}
+
+// Runs the filter's query and writes the page of matching tasks
+func (h *Handler) execute(w http.ResponseWriter, r *http.Request, filter *types.SavedFilter) {
+	query, ok := compile(w, r, filter.Query)
+	if !ok {
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks_list, err := h.taskStore.QueryTasks(*query, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tasks_list)
+}
+
+// Loads the filter the user in the path owns and checks the caller may perform the action on it
+func (h *Handler) getFilter(w http.ResponseWriter, r *http.Request, action policy.Action) (*types.SavedFilter, bool) {
+	vars := mux.Vars(r)
+	userId, _ := strconv.Atoi(vars["id"])
+	filterId, _ := strconv.Atoi(vars["filterId"])
+
+	filter, err := h.store.GetFilterById(filterId)
+	if err != nil || filter.UserId != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get filter by id: filter not found"))
+		return nil, false
+	}
+
+	// Shared filters are read through the project's routes, so only the owner
+	// is allowed here
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), action, policy.Resource{
+		OwnerId: filter.UserId,
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// Loads the filter shared with the project in the path and checks the caller may view it
+func (h *Handler) getProjectFilter(w http.ResponseWriter, r *http.Request) (*types.SavedFilter, bool) {
+	vars := mux.Vars(r)
+	projectId, _ := strconv.Atoi(vars["id"])
+	filterId, _ := strconv.Atoi(vars["filterId"])
+
+	filter, err := h.store.GetFilterById(filterId)
+	if err != nil || filter.ProjectId == nil || *filter.ProjectId != projectId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get filter by id: filter not found"))
+		return nil, false
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	if err := policy.Authorize(user, policy.ViewFilter, policy.Resource{
+		OwnerId:    filter.UserId,
+		MemberRole: policy.ProjectRole(h.projectStore, projectId, user),
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// Validates the payload and its query, and that a shared filter's owner is on the project
+func (h *Handler) parsePayload(w http.ResponseWriter, r *http.Request, ownerId int) (*types.SavedFilterPayload, bool) {
+	var payload types.SavedFilterPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	if _, ok := compile(w, r, payload.Query); !ok {
+		return nil, false
+	}
+
+	if payload.ProjectId != nil {
+		if _, err := h.projectStore.GetProjectMember(*payload.ProjectId, ownerId); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("filters can only be shared with projects their owner is a member of"))
+			return nil, false
+		}
+	}
+
+	return &payload, true
+}
+
+func filterErrorStatus(err error) int {
+	if errors.Is(err, types.ErrFilterExists) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func compile(w http.ResponseWriter, r *http.Request, input string) (*types.TaskQuery, bool) {
+	opts := jql.Options{}
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		opts.CurrentUserId = user.ID
+	}
+
+	query, err := jql.Compile(input, opts)
+	if err != nil {
+		var queryErr *jql.Error
+		if errors.As(err, &queryErr) {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]any{"error": queryErr.Error(), "position": queryErr.Pos})
+			return nil, false
+		}
+
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	return query, true
+}
@@ -0,0 +1,77 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/gorilla/mux"
+)
+
+// Filter 1 is private to user 5 and filter 2 is owned by user 5 and shared
+// with project 1. Users 5 and 6 are members of project 1.
+var filters = map[int]*types.SavedFilter{
+	1: {ID: 1, UserId: 5, Name: "mine", Query: "status = todo"},
+	2: {ID: 2, UserId: 5, ProjectId: intPtr(1), Name: "shared", Query: "status = todo"},
+}
+
+func intPtr(i int) *int { return &i }
+
+type filterStore struct{ types.FilterStore }
+
+func (filterStore) GetFilterById(filterId int) (*types.SavedFilter, error) {
+	if filter, ok := filters[filterId]; ok {
+		return filter, nil
+	}
+
+	return nil, fmt.Errorf("filter not found")
+}
+
+type projectStore struct{ types.ProjectStore }
+
+func (projectStore) GetProjectMember(projectId int, userId int) (*types.ProjectMember, error) {
+	if projectId == 1 && (userId == 5 || userId == 6) {
+		return &types.ProjectMember{ProjectId: projectId, UserId: userId, ProjectRole: types.Viewer}, nil
+	}
+
+	return nil, fmt.Errorf("project member not found")
+}
+
+func TestGetFilterScopesToPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		userId int
+		status int
+	}{
+		{"/users/5/filters/1", 5, http.StatusOK},
+		{"/users/5/filters/2", 6, http.StatusForbidden},
+		{"/users/6/filters/2", 6, http.StatusNotFound},
+		{"/users/7/filters/1", 7, http.StatusNotFound},
+		{"/projects/1/filters/2", 6, http.StatusOK},
+		{"/projects/1/filters/2", 7, http.StatusForbidden},
+		{"/projects/2/filters/2", 6, http.StatusNotFound},
+		{"/projects/1/filters/1", 5, http.StatusNotFound},
+	}
+
+	router := mux.NewRouter()
+	handler := NewHandler(filterStore{}, nil, projectStore{})
+	handler.RegisterRoutes(router.PathPrefix("/users").Subrouter())
+	handler.RegisterProjectRoutes(router.PathPrefix("/projects").Subrouter())
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		user := &types.User{ID: test.userId, UserRole: types.Developer}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, user))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("GET %s as user %d: status = %d, want %d: %s", test.path, test.userId, rr.Code, test.status, rr.Body)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package filters
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Lists the user's own filters followed by those shared with projects they are a member of
+func (s *Store) ListUserFilters(userId int) ([]types.SavedFilter, error) {
+	rows, err := s.db.Query("SELECT f.* FROM saved_filters f "+
+		"WHERE f.userId = $1 OR f.projectId IN (SELECT projectId FROM project_members WHERE userId = $1) "+
+		"ORDER BY f.userId <> $1, f.name", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	filters := []types.SavedFilter{}
+	for rows.Next() {
+		filter, err := ScanRowIntoFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, *filter)
+	}
+
+	return filters, nil
+}
+
+// Lists the filters shared with the project
+func (s *Store) ListProjectFilters(projectId int) ([]types.SavedFilter, error) {
+	rows, err := s.db.Query("SELECT * FROM saved_filters WHERE projectId = $1 ORDER BY name", projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	filters := []types.SavedFilter{}
+	for rows.Next() {
+		filter, err := ScanRowIntoFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, *filter)
+	}
+
+	return filters, nil
+}
+
+func (s *Store) GetFilterById(filterId int) (*types.SavedFilter, error) {
+	rows, err := s.db.Query("SELECT * FROM saved_filters WHERE id = $1", filterId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	filter := new(types.SavedFilter)
+	for rows.Next() {
+		filter, err = ScanRowIntoFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.ID == 0 {
+		return nil, fmt.Errorf("filter not found")
+	}
+
+	return filter, nil
+}
+
+func (s *Store) CreateFilter(filter types.SavedFilter) error {
+	_, err := s.db.Exec("INSERT INTO saved_filters (userId, projectId, name, query) VALUES ($1, $2, $3, $4)",
+		filter.UserId, filter.ProjectId, filter.Name, filter.Query)
+
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateFilter(filterId int, filter types.SavedFilter) error {
+	_, err := s.db.Exec("UPDATE saved_filters SET "+
+		"projectId = $1, name = $2, query = $3, updatedAt = NOW() "+
+		"WHERE id = $4", filter.ProjectId, filter.Name, filter.Query, filterId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update filter: %w", uniqueViolation(err))
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteFilter(filterId int) error {
+	_, err := s.db.Exec("DELETE FROM saved_filters WHERE id = $1", filterId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete filter: %w", err)
+	}
+
+	return nil
+}
+
+// Filter names are unique per user
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.ErrFilterExists
+	}
+
+	return err
+}
+
+func ScanRowIntoFilter(rows *sql.Rows) (*types.SavedFilter, error) {
+	filter := new(types.SavedFilter)
+	var projectId sql.NullInt64
+
+	err := rows.Scan(
+		&filter.ID,
+		&filter.UserId,
+		&projectId,
+		&filter.Name,
+		&filter.Query,
+		&filter.CreatedAt,
+		&filter.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if projectId.Valid {
+		id := int(projectId.Int64)
+		filter.ProjectId = &id
+	}
+
+	return filter, nil
+}
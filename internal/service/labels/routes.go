@@ -0,0 +1,373 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.LabelStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.LabelStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the catalogue routes under the projects router, as every project has its own labels
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/labels", h.handleListLabels).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/labels", h.handleCreateLabel).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/labels/{labelId}", h.handleUpdateLabel).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/labels/{labelId}", h.handleDeleteLabel).Methods(http.MethodDelete)
+}
+
+// Registers the routes that put labels on tasks under the tasks router
+func (h *Handler) RegisterTaskRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/labels", h.handleListTaskLabels).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/labels/{labelId}", h.handleAttachLabel).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/labels/{labelId}", h.handleDetachLabel).Methods(http.MethodDelete)
+}
+
+// @Summary List project labels
+// @Description Get the labels of a project's catalogue, by name
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/labels [get]
+func (h *Handler) handleListLabels(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	labels, err := h.store.ListProjectLabels(project.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, labels)
+}
+
+// @Summary Create a label
+// @Description Add a label to a project's catalogue. Names are unique within the project and colours are given as #rrggbb.
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param label body types.LabelPayload true "Label details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/labels [post]
+func (h *Handler) handleCreateLabel(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageLabels, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateLabel(types.Label{
+		ProjectId: project.ID,
+		Name:      payload.Name,
+		Color:     payload.Color,
+	})
+
+	if err != nil {
+		utils.WriteError(w, labelErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Update a label
+// @Description Rename or recolour a label of the project's catalogue
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param labelId path int true "Label ID"
+// @Param label body types.LabelPayload true "Label details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/labels/{labelId} [put]
+func (h *Handler) handleUpdateLabel(w http.ResponseWriter, r *http.Request) {
+	project, label, ok := h.getLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageLabels, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateLabel(label.ID, types.Label{
+		Name:  payload.Name,
+		Color: payload.Color,
+	})
+
+	if err != nil {
+		utils.WriteError(w, labelErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a label
+// @Description Remove a label from the project's catalogue and from every task that carries it
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param labelId path int true "Label ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/labels/{labelId} [delete]
+func (h *Handler) handleDeleteLabel(w http.ResponseWriter, r *http.Request) {
+	project, label, ok := h.getLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageLabels, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteLabel(label.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary List task labels
+// @Description Get the labels on a task, by name
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {array} types.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/labels [get]
+func (h *Handler) handleListTaskLabels(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	labels, err := h.store.ListTaskLabels(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, labels)
+}
+
+// @Summary Attach a label
+// @Description Put a label of the task's project on the task. Attaching a label the task already has does nothing.
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param labelId path int true "Label ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/labels/{labelId} [put]
+func (h *Handler) handleAttachLabel(w http.ResponseWriter, r *http.Request) {
+	task, label, ok := h.getTaskLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.AttachLabel(task.ID, label.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Attached successfully"})
+}
+
+// @Summary Detach a label
+// @Description Take a label off a task
+// @Tags Labels
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param labelId path int true "Label ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/labels/{labelId} [delete]
+func (h *Handler) handleDetachLabel(w http.ResponseWriter, r *http.Request) {
+	task, label, ok := h.getTaskLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DetachLabel(task.ID, label.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Detached successfully"})
+}
+
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request) (*types.Project, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return nil, false
+	}
+
+	return project, true
+}
+
+// Loads the project and the label from the path, making sure the label is in that project's catalogue
+func (h *Handler) getLabel(w http.ResponseWriter, r *http.Request) (*types.Project, *types.Label, bool) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	labelId, _ := strconv.Atoi(mux.Vars(r)["labelId"])
+
+	label, err := h.store.GetLabelById(labelId)
+	if err != nil || label.ProjectId != project.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get label by id: label not found"))
+		return nil, nil, false
+	}
+
+	return project, label, true
+}
+
+// Loads the task and the label from the path and checks the caller may update
+// the task. Only labels of the task's own project can be put on it.
+func (h *Handler) getTaskLabel(w http.ResponseWriter, r *http.Request) (*types.Task, *types.Label, bool) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return nil, nil, false
+	}
+
+	labelId, _ := strconv.Atoi(mux.Vars(r)["labelId"])
+
+	label, err := h.store.GetLabelById(labelId)
+	if err != nil || label.ProjectId != task.ProjectId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get label by id: label not found in the task's project"))
+		return nil, nil, false
+	}
+
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.UpdateTask, project, policy.Resource{AssigneeId: task.UserId}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, nil, false
+	}
+
+	return task, label, true
+}
+
+func parsePayload(w http.ResponseWriter, r *http.Request) (*types.LabelPayload, bool) {
+	var payload types.LabelPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	payload.Color = strings.ToLower(payload.Color)
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	return &payload, true
+}
+
+func labelErrorStatus(err error) int {
+	if errors.Is(err, types.ErrLabelExists) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
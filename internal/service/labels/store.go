@@ -0,0 +1,190 @@
+package labels
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoLabel expects
+const Columns = "id, projectId, name, color, createdAt"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListProjectLabels(projectId int) ([]types.Label, error) {
+	return s.queryLabels("SELECT "+Columns+" FROM labels WHERE projectId = $1 ORDER BY name", projectId)
+}
+
+func (s *Store) GetLabelById(labelId int) (*types.Label, error) {
+	labels, err := s.queryLabels("SELECT "+Columns+" FROM labels WHERE id = $1", labelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("label not found")
+	}
+
+	return &labels[0], nil
+}
+
+func (s *Store) CreateLabel(label types.Label) error {
+	_, err := s.db.Exec("INSERT INTO labels (projectId, name, color) VALUES ($1, $2, $3)",
+		label.ProjectId, label.Name, label.Color)
+
+	if err != nil {
+		return fmt.Errorf("failed to create label: %w", uniqueViolation(err))
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateLabel(labelId int, label types.Label) error {
+	_, err := s.db.Exec("UPDATE labels SET name = $1, color = $2 WHERE id = $3", label.Name, label.Color, labelId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update label: %w", uniqueViolation(err))
+	}
+
+	return nil
+}
+
+// Deletes a label from the catalogue, which removes it from every task
+func (s *Store) DeleteLabel(labelId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var taskIds []int64
+	err = tx.QueryRow("SELECT ARRAY(SELECT taskId FROM task_labels WHERE labelId = $1 ORDER BY taskId)", labelId).Scan(pq.Array(&taskIds))
+
+	if err != nil {
+		return fmt.Errorf("failed to delete label: %w", err)
+	}
+
+	for _, taskId := range taskIds {
+		if err := detach(tx, int(taskId), labelId, actorId); err != nil {
+			return fmt.Errorf("failed to delete label: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM labels WHERE id = $1", labelId); err != nil {
+		return fmt.Errorf("failed to delete label: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ListTaskLabels(taskId int) ([]types.Label, error) {
+	return s.queryLabels("SELECT "+Columns+" FROM labels "+
+		"WHERE id IN (SELECT labelId FROM task_labels WHERE taskId = $1) ORDER BY name", taskId)
+}
+
+// Puts the label on the task; attaching a label twice is not an error
+func (s *Store) AttachLabel(taskId int, labelId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = tasks.Track(tx, taskId, actorId, func(*types.Task) error {
+		_, err := tx.Exec("INSERT INTO task_labels (taskId, labelId) VALUES ($1, $2) ON CONFLICT DO NOTHING", taskId, labelId)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to attach label: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) DetachLabel(taskId int, labelId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := detach(tx, taskId, labelId, actorId); err != nil {
+		return fmt.Errorf("failed to detach label: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Takes the label off the task, recording the change in the task's audit trail
+func detach(tx *sql.Tx, taskId int, labelId int, actorId int) error {
+	return tasks.Track(tx, taskId, actorId, func(*types.Task) error {
+		_, err := tx.Exec("DELETE FROM task_labels WHERE taskId = $1 AND labelId = $2", taskId, labelId)
+		return err
+	})
+}
+
+func (s *Store) queryLabels(query string, args ...any) ([]types.Label, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	labels := []types.Label{}
+	for rows.Next() {
+		label, err := ScanRowIntoLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		labels = append(labels, *label)
+	}
+
+	return labels, nil
+}
+
+// Label names are unique within a project
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.ErrLabelExists
+	}
+
+	return err
+}
+
+func ScanRowIntoLabel(rows *sql.Rows) (*types.Label, error) {
+	label := new(types.Label)
+
+	err := rows.Scan(
+		&label.ID,
+		&label.ProjectId,
+		&label.Name,
+		&label.Color,
+		&label.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
@@ -0,0 +1,194 @@
+package links
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.LinkStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.LinkStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the routes under the tasks router, as links start from a task
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/links", h.handleListLinks).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/links", h.handleCreateLink).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/links/{linkId}", h.handleDeleteLink).Methods(http.MethodDelete)
+}
+
+// @Summary List task links
+// @Description Get the links from and to a task, oldest first. A link reads as: task_id blocks, relates to or duplicates target_id.
+// @Tags Links
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {array} types.TaskLink
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/links [get]
+func (h *Handler) handleListLinks(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	links, err := h.store.ListTaskLinks(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, links)
+}
+
+// @Summary Link tasks
+// @Description Link the task to another one. Types are blocks, relates_to and duplicates; a task cannot be done while a task that blocks it is open.
+// @Description Blocks and duplicates links that would close a cycle are rejected. The caller must be allowed to update both tasks.
+// @Tags Links
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param link body types.CreateTaskLinkPayload true "Link details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/links [post]
+func (h *Handler) handleCreateLink(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.CreateTaskLinkPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if payload.TargetId == task.ID {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("a task cannot be linked to itself"))
+		return
+	}
+
+	target, err := h.taskStore.GetTaskById(payload.TargetId)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("failed to get target task by id: %v", err))
+		return
+	}
+
+	// A link changes what can be done with the target too, a blocks link
+	// keeps it from being done
+	if err := h.authorize(r, target); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	err = h.store.CreateLink(types.TaskLink{
+		TaskId:   task.ID,
+		TargetId: payload.TargetId,
+		Type:     payload.Type,
+	})
+
+	if err != nil {
+		if errors.Is(err, types.ErrDependencyCycle) || errors.Is(err, types.ErrLinkExists) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Delete task link
+// @Description Remove a link from or to the task
+// @Tags Links
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param linkId path int true "Link ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/links/{linkId} [delete]
+func (h *Handler) handleDeleteLink(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	linkId, _ := strconv.Atoi(mux.Vars(r)["linkId"])
+
+	link, err := h.store.GetLinkById(linkId)
+	if err != nil || (link.TaskId != task.ID && link.TargetId != task.ID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get link by id: link not found"))
+		return
+	}
+
+	if err := h.authorize(r, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteLink(link.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// Linking counts as updating each of the linked tasks: checks the caller
+// against the task's assignee, the project manager and the caller's role in
+// the task's project
+func (h *Handler) authorize(r *http.Request, task *types.Task) error {
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	resource := policy.Resource{AssigneeId: task.UserId}
+
+	return policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.UpdateTask, project, resource)
+}
@@ -0,0 +1,98 @@
+package links
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/gorilla/mux"
+)
+
+// Tasks 1 and 3 are in project 1 and task 2 in project 2. User 5 maintains
+// project 1 only and user 6 maintains both.
+var tasks = map[int]*types.Task{
+	1: {ID: 1, ProjectId: 1, UserId: 9},
+	2: {ID: 2, ProjectId: 2, UserId: 9},
+	3: {ID: 3, ProjectId: 1, UserId: 9},
+}
+
+var roles = map[[2]int]types.ProjectRole{
+	{1, 5}: types.Maintainer,
+	{1, 6}: types.Maintainer,
+	{2, 6}: types.Maintainer,
+}
+
+type taskStore struct{ types.TaskStore }
+
+func (taskStore) GetTaskById(taskId int) (*types.Task, error) {
+	if task, ok := tasks[taskId]; ok {
+		return task, nil
+	}
+
+	return nil, fmt.Errorf("task not found")
+}
+
+type projectStore struct{ types.ProjectStore }
+
+func (projectStore) GetProjectById(projectId int) (*types.Project, error) {
+	return &types.Project{ID: projectId, ManagerId: 100 + projectId}, nil
+}
+
+func (projectStore) GetProjectMember(projectId int, userId int) (*types.ProjectMember, error) {
+	role, ok := roles[[2]int{projectId, userId}]
+	if !ok {
+		return nil, fmt.Errorf("project member not found")
+	}
+
+	return &types.ProjectMember{ProjectId: projectId, UserId: userId, ProjectRole: role}, nil
+}
+
+type linkStore struct {
+	types.LinkStore
+	created []types.TaskLink
+}
+
+func (s *linkStore) CreateLink(link types.TaskLink) error {
+	s.created = append(s.created, link)
+	return nil
+}
+
+func TestCreateLinkAuthorizesTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		userId int
+		target int
+		status int
+	}{
+		{"same project", 5, 3, http.StatusCreated},
+		{"target in a project the caller cannot update", 5, 2, http.StatusForbidden},
+		{"caller can update both projects", 6, 2, http.StatusCreated},
+	}
+
+	for _, test := range tests {
+		links := &linkStore{}
+		router := mux.NewRouter()
+		NewHandler(links, taskStore{}, projectStore{}).RegisterRoutes(router)
+
+		body := fmt.Sprintf(`{"target_id": %d, "type": "blocks"}`, test.target)
+		req := httptest.NewRequest(http.MethodPost, "/1/links", bytes.NewBufferString(body))
+		user := &types.User{ID: test.userId, UserRole: types.Developer}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, user))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, rr.Code, test.status, rr.Body)
+		}
+
+		if created := len(links.created) > 0; created != (test.status == http.StatusCreated) {
+			t.Errorf("%s: link created = %v", test.name, created)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package links
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoLink expects
+const Columns = "id, taskId, targetId, linkType, createdAt"
+
+// Key of the advisory lock that serialises changes to the dependency graph
+const dependencyLock = 0x6c696e6b
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the links from and to a task, oldest first
+func (s *Store) ListTaskLinks(taskId int) ([]types.TaskLink, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM task_dependencies "+
+		"WHERE taskId = $1 OR targetId = $1 ORDER BY createdAt, id", taskId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	links := []types.TaskLink{}
+	for rows.Next() {
+		link, err := ScanRowIntoLink(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, *link)
+	}
+
+	return links, nil
+}
+
+func (s *Store) GetLinkById(linkId int) (*types.TaskLink, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM task_dependencies WHERE id = $1", linkId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	link := new(types.TaskLink)
+	for rows.Next() {
+		link, err = ScanRowIntoLink(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if link.ID == 0 {
+		return nil, fmt.Errorf("link not found")
+	}
+
+	return link, nil
+}
+
+// Adds a link between two tasks. Blocks and duplicates links are directed and
+// may not form a cycle; relates_to links go both ways, so a link in either
+// direction counts as existing.
+func (s *Store) CreateLink(link types.TaskLink) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", dependencyLock); err != nil {
+		return err
+	}
+
+	if link.Type == types.RelatesTo {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM task_dependencies "+
+			"WHERE taskId = $1 AND targetId = $2 AND linkType = $3)", link.TargetId, link.TaskId, link.Type).Scan(&exists)
+
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("failed to create link: %w", types.ErrLinkExists)
+		}
+	} else {
+		var cycle bool
+		err := tx.QueryRow("WITH RECURSIVE reachable (id) AS ("+
+			"SELECT $1::int "+
+			"UNION SELECT task_dependencies.targetId FROM task_dependencies "+
+			"JOIN reachable ON task_dependencies.taskId = reachable.id WHERE task_dependencies.linkType = $3"+
+			") SELECT EXISTS (SELECT 1 FROM reachable WHERE id = $2)", link.TargetId, link.TaskId, link.Type).Scan(&cycle)
+
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return fmt.Errorf("%w: task %d already %s task %d, directly or through other tasks",
+				types.ErrDependencyCycle, link.TargetId, link.Type, link.TaskId)
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO task_dependencies (taskId, targetId, linkType) VALUES ($1, $2, $3)",
+		link.TaskId, link.TargetId, link.Type)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("failed to create link: %w", types.ErrLinkExists)
+		}
+
+		return fmt.Errorf("failed to create link: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) DeleteLink(linkId int) error {
+	_, err := s.db.Exec("DELETE FROM task_dependencies WHERE id = $1", linkId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+
+	return nil
+}
+
+func ScanRowIntoLink(rows *sql.Rows) (*types.TaskLink, error) {
+	link := new(types.TaskLink)
+
+	err := rows.Scan(
+		&link.ID,
+		&link.TaskId,
+		&link.TargetId,
+		&link.Type,
+		&link.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
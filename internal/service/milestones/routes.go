@@ -0,0 +1,346 @@
+package milestones
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.MilestoneStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.MilestoneStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the milestone routes under the projects router, as milestones belong to a project
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/milestones", h.handleListMilestones).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/milestones", h.handleCreateMilestone).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/milestones/{milestoneId}", h.handleGetMilestoneById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/milestones/{milestoneId}", h.handleUpdateMilestone).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/milestones/{milestoneId}", h.handleDeleteMilestone).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/milestones/{milestoneId}/tasks", h.handleListMilestoneTasks).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/milestones/{milestoneId}/release-notes", h.handleReleaseNotes).Methods(http.MethodGet)
+}
+
+// @Summary List project milestones
+// @Description Get the milestones of a project by target date, with their progress. Milestones without a target date come last.
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.Milestone
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones [get]
+func (h *Handler) handleListMilestones(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	milestones, err := h.store.ListProjectMilestones(project.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, milestones)
+}
+
+// @Summary Create a milestone
+// @Description Add a milestone to a project. Tasks are planned for it through their milestone_id.
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestone body types.MilestonePayload true "Milestone details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones [post]
+func (h *Handler) handleCreateMilestone(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageMilestones, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateMilestone(types.Milestone{
+		ProjectId:  project.ID,
+		Title:      payload.Title,
+		Descript:   payload.Descript,
+		TargetDate: payload.TargetDate,
+	})
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get milestone by ID
+// @Description Get a single milestone of a project with its progress
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestoneId path int true "Milestone ID"
+// @Success 200 {object} types.Milestone
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones/{milestoneId} [get]
+func (h *Handler) handleGetMilestoneById(w http.ResponseWriter, r *http.Request) {
+	_, milestone, ok := h.getMilestone(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, milestone)
+}
+
+// @Summary Update a milestone
+// @Description Change the title, description or target date of a milestone
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestoneId path int true "Milestone ID"
+// @Param milestone body types.MilestonePayload true "Milestone details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones/{milestoneId} [put]
+func (h *Handler) handleUpdateMilestone(w http.ResponseWriter, r *http.Request) {
+	project, milestone, ok := h.getMilestone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageMilestones, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateMilestone(milestone.ID, types.Milestone{
+		Title:      payload.Title,
+		Descript:   payload.Descript,
+		TargetDate: payload.TargetDate,
+	})
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a milestone
+// @Description Delete a milestone. Its tasks are kept without a milestone.
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestoneId path int true "Milestone ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones/{milestoneId} [delete]
+func (h *Handler) handleDeleteMilestone(w http.ResponseWriter, r *http.Request) {
+	project, milestone, ok := h.getMilestone(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageMilestones, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteMilestone(milestone.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary List milestone tasks
+// @Description Get the tasks planned for a milestone
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestoneId path int true "Milestone ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones/{milestoneId}/tasks [get]
+func (h *Handler) handleListMilestoneTasks(w http.ResponseWriter, r *http.Request) {
+	_, milestone, ok := h.getMilestone(w, r)
+	if !ok {
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks, err := h.store.ListMilestoneTasks(milestone.ID, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tasks)
+}
+
+// @Summary Milestone release notes
+// @Description List the done tasks of a milestone grouped by label or task type. With group_by=label a task is listed under each of its labels and unlabelled tasks come last in a group without a name.
+// @Tags Milestones
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param milestoneId path int true "Milestone ID"
+// @Param group_by query string false "label or task_type (default)"
+// @Success 200 {object} types.ReleaseNotes
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/milestones/{milestoneId}/release-notes [get]
+func (h *Handler) handleReleaseNotes(w http.ResponseWriter, r *http.Request) {
+	_, milestone, ok := h.getMilestone(w, r)
+	if !ok {
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = GroupByTaskType
+	}
+
+	if groupBy != GroupByLabel && groupBy != GroupByTaskType {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("group_by must be %s or %s", GroupByLabel, GroupByTaskType))
+		return
+	}
+
+	groups, err := h.store.ReleaseNotes(milestone.ID, groupBy)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.ReleaseNotes{
+		Milestone: *milestone,
+		GroupBy:   groupBy,
+		Groups:    groups,
+	})
+}
+
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request) (*types.Project, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return nil, false
+	}
+
+	return project, true
+}
+
+// Loads the project and the milestone from the path, making sure the milestone belongs to that project
+func (h *Handler) getMilestone(w http.ResponseWriter, r *http.Request) (*types.Project, *types.Milestone, bool) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	milestoneId, _ := strconv.Atoi(mux.Vars(r)["milestoneId"])
+
+	milestone, err := h.store.GetMilestoneById(milestoneId)
+	if err != nil || milestone.ProjectId != project.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get milestone by id: milestone not found"))
+		return nil, nil, false
+	}
+
+	return project, milestone, true
+}
+
+func parsePayload(w http.ResponseWriter, r *http.Request) (*types.MilestonePayload, bool) {
+	var payload types.MilestonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	payload.Title = strings.TrimSpace(payload.Title)
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	return &payload, true
+}
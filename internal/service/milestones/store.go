@@ -0,0 +1,204 @@
+package milestones
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+)
+
+// Columns is the select list ScanRowIntoMilestone expects. The counts and the
+// remaining estimate are computed from the milestone's tasks.
+const Columns = "id, projectId, title, descript, targetDate, " +
+	"(SELECT COUNT(*) FROM tasks WHERE tasks.milestoneId = milestones.id), " +
+	"(SELECT COUNT(*) FROM tasks WHERE tasks.milestoneId = milestones.id AND " + workflows.CategoryColumn + " = 'done'), " +
+	"(SELECT COALESCE(SUM(remainingEstimate), 0) FROM tasks WHERE tasks.milestoneId = milestones.id AND " +
+	workflows.CategoryColumn + " IS DISTINCT FROM 'done'), " +
+	"createdAt, updatedAt"
+
+// Ways release notes can be grouped
+const (
+	GroupByLabel    = "label"
+	GroupByTaskType = "task_type"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the milestones of a project by target date, undated ones last
+func (s *Store) ListProjectMilestones(projectId int) ([]types.Milestone, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM milestones WHERE projectId = $1 ORDER BY targetDate NULLS LAST, id", projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	milestones := []types.Milestone{}
+	for rows.Next() {
+		milestone, err := ScanRowIntoMilestone(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		milestones = append(milestones, *milestone)
+	}
+
+	return milestones, nil
+}
+
+func (s *Store) GetMilestoneById(milestoneId int) (*types.Milestone, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM milestones WHERE id = $1", milestoneId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	milestone := new(types.Milestone)
+	for rows.Next() {
+		milestone, err = ScanRowIntoMilestone(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if milestone.ID == 0 {
+		return nil, fmt.Errorf("milestone not found")
+	}
+
+	return milestone, nil
+}
+
+func (s *Store) CreateMilestone(milestone types.Milestone) error {
+	_, err := s.db.Exec("INSERT INTO milestones (projectId, title, descript, targetDate) VALUES ($1, $2, $3, $4)",
+		milestone.ProjectId, milestone.Title, milestone.Descript, milestone.TargetDate)
+
+	if err != nil {
+		return fmt.Errorf("failed to create milestone: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateMilestone(milestoneId int, milestone types.Milestone) error {
+	_, err := s.db.Exec("UPDATE milestones SET title = $1, descript = $2, targetDate = $3, updatedAt = NOW() WHERE id = $4",
+		milestone.Title, milestone.Descript, milestone.TargetDate, milestoneId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update milestone: %w", err)
+	}
+
+	return nil
+}
+
+// Deletes a milestone; its tasks are kept without a milestone
+func (s *Store) DeleteMilestone(milestoneId int) error {
+	_, err := s.db.Exec("DELETE FROM milestones WHERE id = $1", milestoneId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete milestone: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) ListMilestoneTasks(milestoneId int, params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: tasks.Columns,
+		From:   "tasks",
+		Where:  "milestoneId = $1",
+		Args:   []any{milestoneId},
+	}, tasks.Keyset, params, tasks.ScanRowIntoTask)
+}
+
+// Returns the done tasks of a milestone grouped by label or task type, each
+// group in the order the tasks were completed. Groups are sorted by name; a
+// task with several labels is listed under each and tasks without labels
+// come last, in a group without a name.
+func (s *Store) ReleaseNotes(milestoneId int, groupBy string) ([]types.ReleaseNoteGroup, error) {
+	rows, err := s.db.Query("SELECT "+tasks.Columns+" FROM tasks WHERE milestoneId = $1 AND "+workflows.CategoryColumn+" = 'done' "+
+		"ORDER BY completedAt, id", milestoneId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	groups := map[string][]types.Task{}
+	for rows.Next() {
+		task, err := tasks.ScanRowIntoTask(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{string(task.TaskType)}
+		if groupBy == GroupByLabel {
+			names = task.Labels
+			if len(names) == 0 {
+				names = []string{""}
+			}
+		}
+
+		for _, name := range names {
+			groups[name] = append(groups[name], *task)
+		}
+	}
+
+	notes := []types.ReleaseNoteGroup{}
+	for name, grouped := range groups {
+		notes = append(notes, types.ReleaseNoteGroup{Name: name, Tasks: grouped})
+	}
+
+	slices.SortFunc(notes, func(a, b types.ReleaseNoteGroup) int {
+		if a.Name == "" || b.Name == "" {
+			return strings.Compare(b.Name, a.Name)
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return notes, nil
+}
+
+func ScanRowIntoMilestone(rows *sql.Rows) (*types.Milestone, error) {
+	milestone := new(types.Milestone)
+
+	err := rows.Scan(
+		&milestone.ID,
+		&milestone.ProjectId,
+		&milestone.Title,
+		&milestone.Descript,
+		&milestone.TargetDate,
+		&milestone.TotalTasks,
+		&milestone.DoneTasks,
+		&milestone.RemainingEstimate,
+		&milestone.CreatedAt,
+		&milestone.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if milestone.TotalTasks > 0 {
+		milestone.Progress = milestone.DoneTasks * 100 / milestone.TotalTasks
+	}
+
+	return milestone, nil
+}
@@ -1,10 +1,15 @@
 package projects
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/pkg/storage"
 	"github.com/4lerman/pm_service/types"
 	"github.com/4lerman/pm_service/utils"
 	"github.com/go-playground/validator/v10"
@@ -12,12 +17,16 @@ import (
 )
 
 type Handler struct {
-	store types.ProjectStore
+	store     types.ProjectStore
+	teamStore types.TeamStore
+	files     storage.Storage
 }
 
-func NewHandler(store types.ProjectStore) *Handler {
+func NewHandler(store types.ProjectStore, teamStore types.TeamStore, files storage.Storage) *Handler {
 	return &Handler{
-		store: store,
+		store:     store,
+		teamStore: teamStore,
+		files:     files,
 	}
 }
 
@@ -29,6 +38,10 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/{id}", h.handleUpdateProject).Methods(http.MethodPut)
 	router.HandleFunc("/{id}", h.handleDeleteProject).Methods(http.MethodDelete)
 	router.HandleFunc("/{id}/tasks", h.handleGetProjectTasks).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/summary", h.handleGetProjectSummary).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/members", h.handleListProjectMembers).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/members", h.handleAddProjectMember).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/members/{userId}", h.handleRemoveProjectMember).Methods(http.MethodDelete)
 }
 
 // @Summary List all projects
@@ -36,29 +49,47 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 // @Tags Projects
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} types.Project
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Project]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects [get]
 func (h *Handler) handleListProjects(w http.ResponseWriter, r *http.Request) {
-	projects, err := h.store.ListProjects()
+	params, err := utils.ParseListParams(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	projects, err := h.store.ListProjects(params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, projects)
 }
 
 // @Summary Create a new project
-// @Description Create a new project with the given details
+// @Description Create a new project with the given details, optionally owned by a team
 // @Tags Projects
 // @Accept  json
 // @Produce  json
 // @Param project body types.CreateProjectPayload true "Project details"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects [post]
 func (h *Handler) handleCreateProject(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.CreateProject, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	var payload types.CreateProjectPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
@@ -71,11 +102,17 @@ func (h *Handler) handleCreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.validateTeam(payload.TeamId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	err := h.store.CreateProject(types.Project{
 		Title:     payload.Title,
 		Descript:  payload.Descript,
 		ManagerId: payload.ManagerId,
-	})
+		TeamId:    payload.TeamId,
+	}, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
@@ -86,15 +123,17 @@ func (h *Handler) handleCreateProject(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Search projects by query
-// @Description Search projects by title or manager ID
+// @Description Search projects by title, manager ID or owning team ID
 // @Tags Projects
 // @Accept  json
 // @Produce  json
 // @Param title query string false "Project title"
 // @Param manager query string false "Manager ID"
+// @Param team query string false "Owning team ID"
 // @Success 200 {array} types.Project
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects/search [get]
 func (h *Handler) handleProjectByQuery(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
@@ -106,6 +145,9 @@ func (h *Handler) handleProjectByQuery(w http.ResponseWriter, r *http.Request) {
 	} else if manager := queryParams.Get("manager"); manager != "" {
 		queryType = "manager"
 		query = manager
+	} else if team := queryParams.Get("team"); team != "" {
+		queryType = "team"
+		query = team
 	} else {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid query parameters"))
 		return
@@ -129,6 +171,7 @@ func (h *Handler) handleProjectByQuery(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} types.Project
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects/{id} [get]
 func (h *Handler) handleGetProjectById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -151,7 +194,7 @@ func (h *Handler) handleGetProjectById(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update project details
-// @Description Update project details by ID
+// @Description Update project details by ID. A project without team_id has no owning team.
 // @Tags Projects
 // @Accept  json
 // @Produce  json
@@ -159,8 +202,10 @@ func (h *Handler) handleGetProjectById(w http.ResponseWriter, r *http.Request) {
 // @Param project body types.UpdateProjectPayload true "Project details"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects/{id} [put]
 func (h *Handler) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -173,6 +218,17 @@ func (h *Handler) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
 
 	projectId, _ := strconv.Atoi(id)
 
+	project, err := h.store.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.store, auth.GetUserFromContext(r.Context()), policy.UpdateProject, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	var payload types.UpdateProjectPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
@@ -185,11 +241,17 @@ func (h *Handler) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.store.UpdateProject(projectId, types.Project{
+	if err := h.validateTeam(payload.TeamId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.store.UpdateProject(projectId, types.Project{
 		Title:     payload.Title,
 		Descript:  payload.Descript,
 		ManagerId: payload.ManagerId,
-	})
+		TeamId:    payload.TeamId,
+	}, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
@@ -207,8 +269,10 @@ func (h *Handler) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "Project ID"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects/{id} [delete]
 func (h *Handler) handleDeleteProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -221,11 +285,25 @@ func (h *Handler) handleDeleteProject(w http.ResponseWriter, r *http.Request) {
 
 	projectId, _ := strconv.Atoi(id)
 
-	if err := h.store.DeleteProject(projectId); err != nil {
+	project, err := h.store.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.store, auth.GetUserFromContext(r.Context()), policy.DeleteProject, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	keys, err := h.store.DeleteProject(projectId, auth.GetUserIdFromContext(r.Context()))
+	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	storage.DeleteAll(r.Context(), h.files, keys)
+
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
 }
 
@@ -235,10 +313,14 @@ func (h *Handler) handleDeleteProject(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Project ID"
-// @Success 200 {array} types.Task
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /projects/{id}/tasks [get]
 func (h *Handler) handleGetProjectTasks(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -255,11 +337,242 @@ func (h *Handler) handleGetProjectTasks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tasks_list, err := h.store.GetProjectTasks(projectId)
+	params, err := utils.ParseListParams(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks_list, err := h.store.GetProjectTasks(projectId, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, tasks_list)
 }
+
+// @Summary Get project summary
+// @Description Get the dashboard summary of a project: task counts by status, task type and assignee, overdue counts,
+// @Description the most recently updated tasks and the completion percentage
+// @Tags Projects
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {object} types.ProjectSummary
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/summary [get]
+func (h *Handler) handleGetProjectSummary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+	if _, err := h.store.GetProjectById(projectId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	summary, err := h.store.GetProjectSummary(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, summary)
+}
+
+// @Summary List project members
+// @Description Get the members of a project with their project roles
+// @Tags Projects
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.ProjectMember
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/members [get]
+func (h *Handler) handleListProjectMembers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+	if _, err := h.store.GetProjectById(projectId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	members, err := h.store.ListProjectMembers(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, members)
+}
+
+// @Summary Add project member
+// @Description Add a user to a project, or change the project role of an existing member. The project manager always stays an owner.
+// @Description Only the project manager and owners can grant or take away the owner and maintainer roles.
+// @Tags Projects
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param member body types.AddProjectMemberPayload true "Member details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/members [post]
+func (h *Handler) handleAddProjectMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.store.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.store, auth.GetUserFromContext(r.Context()), policy.ManageProjectMembers, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.AddProjectMemberPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if err := h.authorizeRoleChange(r, project, payload.UserId, payload.ProjectRole); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	err = h.store.AddProjectMember(types.ProjectMember{
+		ProjectId:   projectId,
+		UserId:      payload.UserId,
+		ProjectRole: payload.ProjectRole,
+	})
+
+	if errors.Is(err, types.ErrManagerRole) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Member added successfully"})
+}
+
+// @Summary Remove project member
+// @Description Remove a user from a project. The project manager cannot be removed, and only the project manager and owners can remove owners and maintainers.
+// @Tags Projects
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/members/{userId} [delete]
+func (h *Handler) handleRemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+	userId, _ := strconv.Atoi(vars["userId"])
+
+	project, err := h.store.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.store, auth.GetUserFromContext(r.Context()), policy.ManageProjectMembers, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.authorizeRoleChange(r, project, userId, ""); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.RemoveProjectMember(projectId, userId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Member removed successfully"})
+}
+
+// Only the project manager and owners may make a member an owner or a
+// maintainer, or take either role away, so that maintainers cannot promote
+// anyone, themselves included. An empty role removes the member.
+func (h *Handler) authorizeRoleChange(r *http.Request, project *types.Project, userId int, role types.ProjectRole) error {
+	current := types.ProjectRole("")
+	if member, err := h.store.GetProjectMember(project.ID, userId); err == nil {
+		current = member.ProjectRole
+	}
+
+	elevated := []types.ProjectRole{types.Owner, types.Maintainer}
+	if !slices.Contains(elevated, role) && !slices.Contains(elevated, current) {
+		return nil
+	}
+
+	return policy.AuthorizeProject(h.store, auth.GetUserFromContext(r.Context()), policy.ManageProjectAdmins, project, policy.Resource{})
+}
+
+// The owning team of a project must exist
+func (h *Handler) validateTeam(teamId *int) error {
+	if teamId == nil {
+		return nil
+	}
+
+	if _, err := h.teamStore.GetTeamById(*teamId); err != nil {
+		return fmt.Errorf("team %d not found", *teamId)
+	}
+
+	return nil
+}
@@ -2,12 +2,34 @@ package projects
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 
+	"github.com/4lerman/pm_service/internal/service/attachments"
+	"github.com/4lerman/pm_service/internal/service/audit"
+	"github.com/4lerman/pm_service/internal/service/fields"
 	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
 	"github.com/4lerman/pm_service/types"
 )
 
+// Columns is the select list ScanRowIntoProject expects
+const Columns = "id, title, descript, createdAt, updatedAt, managerId, teamId, " + fields.ProjectColumn
+
+// Keyset lists the fields projects can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":         "id",
+	"title":      "title",
+	"manager_id": "managerId",
+	"team_id":    "teamId",
+	"created_at": "createdAt",
+	"updated_at": "updatedAt",
+}
+
+// Sort fields of Keyset whose columns can be NULL
+var nullable = []string{"team_id"}
+
 type Store struct {
 	db *sql.DB
 }
@@ -18,41 +40,48 @@ func NewStore(db *sql.DB) *Store {
 	}
 }
 
-func (s *Store) ListProjects() ([]types.Project, error) {
-	rows, err := s.db.Query("SELECT * FROM projects")
+func (s *Store) ListProjects(params types.ListParams) (*types.Page[types.Project], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select:   Columns,
+		From:     "projects",
+		Nullable: nullable,
+	}, Keyset, params, ScanRowIntoProject)
+}
 
+func (s *Store) CreateProject(project types.Project, actorId int) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	defer tx.Rollback()
 
-	projects := []types.Project{}
-	for rows.Next() {
-		project, err := ScanRowIntoProject(rows)
-		if err != nil {
-			return nil, err
-		}
+	created, err := queryProject(tx, "INSERT INTO projects (title, descript, managerId, teamId) VALUES ($1, $2, $3, $4) RETURNING "+Columns,
+		project.Title, project.Descript, project.ManagerId, project.TeamId)
 
-		projects = append(projects, *project)
+	if err != nil {
+		return err
 	}
 
-	return projects, nil
-}
+	projectId := created.ID
 
-func (s *Store) CreateProject(project types.Project) error {
-	_, err := s.db.Exec("INSERT INTO projects (title, descript, managerId) VALUES ($1, $2, $3)",
-		project.Title, project.Descript, project.ManagerId)
+	if err := upsertProjectMember(tx, projectId, project.ManagerId, types.Owner); err != nil {
+		return err
+	}
 
-	if err != nil {
+	if err := workflows.SaveWorkflow(tx, projectId, workflows.Default); err != nil {
 		return err
 	}
 
-	return nil
+	if err := record(tx, nil, created, types.AuditCreate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *Store) GetProjectById(projectId int) (*types.Project, error) {
-	rows, err := s.db.Query("SELECT * FROM projects WHERE id = $1", projectId)
+	rows, err := s.db.Query("SELECT "+Columns+" FROM projects WHERE id = $1", projectId)
 
 	if err != nil {
 		return nil, err
@@ -80,10 +109,12 @@ func (s *Store) GetProjectsByQuery(queryType string, query string) ([]types.Proj
 
 	switch queryType {
 	case "title":
-		sqlQuery = "SELECT * FROM projects WHERE title ILIKE $1"
+		sqlQuery = "SELECT " + Columns + " FROM projects WHERE title ILIKE $1"
 		query = "%" + query + "%"
 	case "manager":
-		sqlQuery = "SELECT * FROM projects WHERE managerId = $1"
+		sqlQuery = "SELECT " + Columns + " FROM projects WHERE managerId = $1"
+	case "team":
+		sqlQuery = "SELECT " + Columns + " FROM projects WHERE teamId = $1"
 	default:
 		return nil, fmt.Errorf("invalid query type: %s", queryType)
 	}
@@ -108,30 +139,140 @@ func (s *Store) GetProjectsByQuery(queryType string, query string) ([]types.Proj
 	return projects, nil
 }
 
-func (s *Store) UpdateProject(projectId int, project types.Project) error {
-	_, err := s.db.Exec("UPDATE projects SET "+
-		"title = $1, descript = $2, managerId = $3, updatedAt = NOW() "+
-		"WHERE id = $4", project.Title, project.Descript, project.ManagerId, projectId)
+func (s *Store) UpdateProject(projectId int, project types.Project, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
 
+	before, err := queryProject(tx, "SELECT "+Columns+" FROM projects WHERE id = $1 FOR UPDATE", projectId)
 	if err != nil {
 		return fmt.Errorf("failed to update project: %w", err)
 	}
 
-	return nil
+	after, err := queryProject(tx, "UPDATE projects SET "+
+		"title = $1, descript = $2, managerId = $3, teamId = $4, updatedAt = NOW() "+
+		"WHERE id = $5 RETURNING "+Columns, project.Title, project.Descript, project.ManagerId, project.TeamId, projectId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+
+	if err := upsertProjectMember(tx, projectId, project.ManagerId, types.Owner); err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+
+	if err := record(tx, before, after, types.AuditUpdate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *Store) DeleteProject(projectId int) error {
-	_, err := s.db.Exec("DELETE FROM projects WHERE id = $1", )
+// Deletes a project and returns the storage keys of the attachments of its
+// tasks, whose content the caller removes
+func (s *Store) DeleteProject(projectId int, actorId int) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
 
+	keys, err := attachments.StorageKeys(tx, "projectId = $1", projectId)
 	if err != nil {
-		return fmt.Errorf("failed to delete project: %w", err)
+		return nil, fmt.Errorf("failed to delete project: %w", err)
 	}
 
-	return nil
+	before, err := queryProject(tx, "DELETE FROM projects WHERE id = $1 RETURNING "+Columns, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete project: %w", err)
+	}
+
+	if err := record(tx, before, nil, types.AuditDelete, actorId); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
 }
 
-func (s *Store) GetProjectTasks(projectId int) ([]types.Task, error) {
-	rows, err := s.db.Query("SELECT * FROM tasks WHERE projectId = $1", projectId)
+func (s *Store) GetProjectTasks(projectId int, params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: tasks.Columns,
+		From:   "tasks",
+		Where:  "projectId = $1",
+		Args:   []any{projectId},
+	}, tasks.Keyset, params, tasks.ScanRowIntoTask)
+}
+
+// Number of recently updated tasks a project summary lists
+const recentTasks = 10
+
+// Computes the dashboard summary of a project in a single statement. The
+// project's tasks are read once into project_tasks and every breakdown is
+// aggregated from there; statuses and task types without tasks are listed
+// with a count of 0.
+func (s *Store) GetProjectSummary(projectId int) (*types.ProjectSummary, error) {
+	summary := &types.ProjectSummary{ProjectId: projectId}
+
+	var byStatus, byTaskType, byAssignee, recent []byte
+
+	err := s.db.QueryRow("WITH project_tasks AS MATERIALIZED ("+
+		"SELECT id, title, status, taskType, userId, updatedAt, "+workflows.CategoryColumn+" AS category, "+
+		"(dueDate < CURRENT_DATE AND "+workflows.CategoryColumn+" <> 'done') AS overdue "+
+		"FROM tasks WHERE projectId = $1"+
+		") SELECT "+
+		"(SELECT COUNT(*) FROM project_tasks), "+
+		"(SELECT COUNT(*) FROM project_tasks WHERE category = 'done'), "+
+		"(SELECT COUNT(*) FROM project_tasks WHERE overdue), "+
+		"(SELECT COALESCE(json_agg(json_build_object('status', workflow_states.name, 'category', workflow_states.category, "+
+		"'count', COALESCE(counts.count, 0)) ORDER BY workflow_states.position), '[]') "+
+		"FROM workflow_states LEFT JOIN (SELECT status, COUNT(*) AS count FROM project_tasks GROUP BY status) AS counts "+
+		"ON counts.status = workflow_states.name WHERE workflow_states.projectId = $1), "+
+		"(SELECT COALESCE(json_agg(json_build_object('task_type', task_types.taskType, 'count', COALESCE(counts.count, 0)) "+
+		"ORDER BY task_types.taskType), '[]') "+
+		"FROM unnest(enum_range(NULL::task_type)) AS task_types(taskType) "+
+		"LEFT JOIN (SELECT taskType, COUNT(*) AS count FROM project_tasks GROUP BY taskType) AS counts "+
+		"ON counts.taskType = task_types.taskType), "+
+		"(SELECT COALESCE(json_agg(json_build_object('user_id', counts.userId, 'full_name', COALESCE(users.fullName, ''), "+
+		"'count', counts.count, 'overdue', counts.overdue) ORDER BY counts.count DESC, counts.userId), '[]') "+
+		"FROM (SELECT userId, COUNT(*) AS count, COUNT(*) FILTER (WHERE overdue) AS overdue FROM project_tasks GROUP BY userId) AS counts "+
+		"LEFT JOIN users ON users.id = counts.userId), "+
+		"(SELECT COALESCE(json_agg(json_build_object('id', id, 'title', title, 'status', status, 'user_id', userId, "+
+		"'updated_at', updatedAt AT TIME ZONE 'UTC') ORDER BY updatedAt DESC, id DESC), '[]') "+
+		"FROM (SELECT * FROM project_tasks ORDER BY updatedAt DESC, id DESC LIMIT $2) AS recent)",
+		projectId, recentTasks).Scan(&summary.Total, &summary.Done, &summary.Overdue, &byStatus, &byTaskType, &byAssignee, &recent)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise project: %w", err)
+	}
+
+	for target, data := range map[any][]byte{
+		&summary.ByStatus:        byStatus,
+		&summary.ByTaskType:      byTaskType,
+		&summary.ByAssignee:      byAssignee,
+		&summary.RecentlyUpdated: recent,
+	} {
+		if err := json.Unmarshal(data, target); err != nil {
+			return nil, err
+		}
+	}
+
+	if summary.Total > 0 {
+		summary.Completion = summary.Done * 100 / summary.Total
+	}
+
+	return summary, nil
+}
+
+func (s *Store) ListProjectMembers(projectId int) ([]types.ProjectMember, error) {
+	rows, err := s.db.Query("SELECT * FROM project_members WHERE projectId = $1 ORDER BY addedAt", projectId)
 
 	if err != nil {
 		return nil, err
@@ -139,22 +280,147 @@ func (s *Store) GetProjectTasks(projectId int) ([]types.Task, error) {
 
 	defer rows.Close()
 
-	tasks_list := []types.Task{}
+	members := []types.ProjectMember{}
 	for rows.Next() {
-		task, err := tasks.ScanRowIntoTask(rows)
+		member, err := ScanRowIntoProjectMember(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		tasks_list = append(tasks_list, *task)
+		members = append(members, *member)
 	}
 
-	return tasks_list, nil
+	return members, nil
+}
+
+func (s *Store) GetProjectMember(projectId int, userId int) (*types.ProjectMember, error) {
+	rows, err := s.db.Query("SELECT * FROM project_members WHERE projectId = $1 AND userId = $2", projectId, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	member := new(types.ProjectMember)
+	for rows.Next() {
+		member, err = ScanRowIntoProjectMember(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if member.UserId == 0 {
+		return nil, fmt.Errorf("project member not found")
+	}
+
+	return member, nil
+}
+
+// Adds a member or changes their role. The project manager's role is only
+// ever left at owner, so no member can demote them
+func (s *Store) AddProjectMember(member types.ProjectMember) error {
+	res, err := s.db.Exec("INSERT INTO project_members (projectId, userId, projectRole) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (projectId, userId) DO UPDATE SET projectRole = EXCLUDED.projectRole "+
+		"WHERE EXCLUDED.projectRole = $4 OR project_members.userId <> (SELECT managerId FROM projects WHERE id = $1)",
+		member.ProjectId, member.UserId, member.ProjectRole, types.Owner)
+
+	if err != nil {
+		return fmt.Errorf("failed to add project member: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to add project member: %w", types.ErrManagerRole)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveProjectMember(projectId int, userId int) error {
+	res, err := s.db.Exec("DELETE FROM project_members WHERE projectId = $1 AND userId = $2 "+
+		"AND userId <> (SELECT managerId FROM projects WHERE id = $1)", projectId, userId)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove project member: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to remove project member: not a member or the project manager")
+	}
+
+	return nil
+}
+
+// Runs a statement that returns a single project row, such as an INSERT or UPDATE with RETURNING
+func queryProject(tx *sql.Tx, query string, args ...any) (*types.Project, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("project not found")
+	}
+
+	return ScanRowIntoProject(rows)
+}
+
+// Records the change between two versions of a project in the audit trail
+func record(tx *sql.Tx, before *types.Project, after *types.Project, action types.AuditAction, actorId int) error {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	project := after
+	if project == nil {
+		project = before
+	}
+
+	return audit.Record(tx, types.AuditProject, project.ID, action, actorId, changes)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func upsertProjectMember(db execer, projectId int, userId int, role types.ProjectRole) error {
+	_, err := db.Exec("INSERT INTO project_members (projectId, userId, projectRole) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (projectId, userId) DO UPDATE SET projectRole = EXCLUDED.projectRole",
+		projectId, userId, role)
+
+	return err
+}
+
+func ScanRowIntoProjectMember(rows *sql.Rows) (*types.ProjectMember, error) {
+	member := new(types.ProjectMember)
+
+	err := rows.Scan(
+		&member.ProjectId,
+		&member.UserId,
+		&member.ProjectRole,
+		&member.AddedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
 }
 
 func ScanRowIntoProject(rows *sql.Rows) (*types.Project, error) {
 	project := new(types.Project)
 
+	var teamId sql.NullInt64
+	var customFields []byte
+
 	err := rows.Scan(
 		&project.ID,
 		&project.Title,
@@ -162,11 +428,22 @@ func ScanRowIntoProject(rows *sql.Rows) (*types.Project, error) {
 		&project.CreatedAt,
 		&project.UpdatedAt,
 		&project.ManagerId,
+		&teamId,
+		&customFields,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if teamId.Valid {
+		id := int(teamId.Int64)
+		project.TeamId = &id
+	}
+
+	if err := json.Unmarshal(customFields, &project.CustomFields); err != nil {
+		return nil, err
+	}
+
 	return project, nil
 }
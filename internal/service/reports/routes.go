@@ -0,0 +1,149 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/gorilla/mux"
+)
+
+// Longest range a report can cover, in days
+const maxRange = 366
+
+type Handler struct {
+	store        types.ReportStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.ReportStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the report routes under the projects router, as reports cover a project
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/reports/burndown", h.handleBurndown).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/reports/cycle-time", h.handleCycleTime).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/reports/throughput", h.handleThroughput).Methods(http.MethodGet)
+}
+
+// @Summary Project burndown
+// @Description Get the daily burndown and burnup series of a project between two days, inclusive, computed from the status history of its tasks.
+// @Description Each point is taken at the end of the day: scope is the number of tasks created by then, done those in a done state and remaining the rest.
+// @Tags Reports
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.BurndownReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/reports/burndown [get]
+func (h *Handler) handleBurndown(w http.ResponseWriter, r *http.Request) {
+	project, from, to, ok := h.parseReport(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.store.Burndown(project.ID, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// @Summary Project cycle and lead time
+// @Description Get the 50th, 85th and 95th percentile of the cycle and lead time, in hours, of the project's tasks completed between two days, inclusive.
+// @Description Lead time runs from creation to completion; cycle time from the first move to an in progress state to completion.
+// @Tags Reports
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.CycleTimeReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/reports/cycle-time [get]
+func (h *Handler) handleCycleTime(w http.ResponseWriter, r *http.Request) {
+	project, from, to, ok := h.parseReport(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.store.CycleTime(project.ID, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// @Summary Project throughput
+// @Description Get the number of the project's tasks completed in each week between two days, inclusive. Weeks start on Monday and are listed even when nothing was completed.
+// @Tags Reports
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.ThroughputReport
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/reports/throughput [get]
+func (h *Handler) handleThroughput(w http.ResponseWriter, r *http.Request) {
+	project, from, to, ok := h.parseReport(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.store.Throughput(project.ID, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// Loads the project from the path and the range of the report from the query string
+func (h *Handler) parseReport(w http.ResponseWriter, r *http.Request) (*types.Project, types.Date, types.Date, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, types.Date{}, types.Date{}, false
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return nil, types.Date{}, types.Date{}, false
+	}
+
+	from, to, err := utils.ParseDateRange(r, maxRange)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, types.Date{}, types.Date{}, false
+	}
+
+	return project, from, to, true
+}
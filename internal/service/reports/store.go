@@ -0,0 +1,155 @@
+package reports
+
+import (
+	"database/sql"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// The status a task had at the end of the day days.day, read from the audit
+// trail. It is NULL for tasks created before the trail was kept, which are
+// taken to have always had their current status.
+const statusAtDay = "LEFT JOIN LATERAL (" +
+	"SELECT audit_events.changes->'status'->>'new' AS status FROM audit_events " +
+	"WHERE audit_events.entityType = 'task' AND audit_events.entityId = tasks.id AND audit_events.changes ? 'status' " +
+	"AND audit_events.createdAt < days.day + interval '1 day' " +
+	"ORDER BY audit_events.id DESC LIMIT 1" +
+	") AS history ON true "
+
+// The earliest moment a task entered an in progress state
+const startedAt = "(SELECT MIN(audit_events.createdAt) FROM audit_events " +
+	"JOIN workflow_states ON workflow_states.projectId = tasks.projectId AND workflow_states.name = audit_events.changes->'status'->>'new' " +
+	"WHERE audit_events.entityType = 'task' AND audit_events.entityId = tasks.id AND audit_events.createdAt <= tasks.completedAt " +
+	"AND workflow_states.category = 'in_progress')"
+
+// completedInRange matches tasks completed on or after the day $2 and before
+// the end of the day $3
+const completedInRange = "tasks.completedAt >= $2::date AND tasks.completedAt < $3::date + 1"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the scope and the done tasks of a project at the end of every day
+// between two days. Tasks count towards the project they are in now from the
+// day they were created; deleted tasks are left out.
+func (s *Store) Burndown(projectId int, from types.Date, to types.Date) (*types.BurndownReport, error) {
+	rows, err := s.db.Query("SELECT days.day::date, COUNT(tasks.id), "+
+		"COUNT(tasks.id) FILTER (WHERE workflow_states.category = 'done') "+
+		"FROM generate_series($2::date, $3::date, interval '1 day') AS days(day) "+
+		"LEFT JOIN tasks ON tasks.projectId = $1 AND tasks.createdAt < days.day + interval '1 day' "+
+		statusAtDay+
+		"LEFT JOIN workflow_states ON workflow_states.projectId = tasks.projectId "+
+		"AND workflow_states.name = COALESCE(history.status, tasks.status) "+
+		"GROUP BY days.day ORDER BY days.day", projectId, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	report := &types.BurndownReport{
+		From:   from,
+		To:     to,
+		Points: []types.BurndownPoint{},
+	}
+
+	for rows.Next() {
+		var point types.BurndownPoint
+		if err := rows.Scan(&point.Date, &point.Scope, &point.Done); err != nil {
+			return nil, err
+		}
+
+		point.Remaining = point.Scope - point.Done
+		report.Points = append(report.Points, point)
+	}
+
+	return report, nil
+}
+
+// Returns the cycle and lead time percentiles of the project's tasks that
+// were completed between two days. Tasks that went straight to done have no
+// cycle time.
+func (s *Store) CycleTime(projectId int, from types.Date, to types.Date) (*types.CycleTimeReport, error) {
+	report := &types.CycleTimeReport{
+		From: from,
+		To:   to,
+	}
+
+	var cycleTimes, leadTimes []sql.NullFloat64
+
+	err := s.db.QueryRow("SELECT COUNT(*), COUNT(startedAt), "+
+		"percentile_cont(ARRAY[0.5, 0.85, 0.95]) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM completedAt - startedAt) / 3600), "+
+		"percentile_cont(ARRAY[0.5, 0.85, 0.95]) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM completedAt - createdAt) / 3600) "+
+		"FROM (SELECT tasks.createdAt, tasks.completedAt, "+startedAt+" AS startedAt "+
+		"FROM tasks WHERE tasks.projectId = $1 AND "+completedInRange+") AS completed", projectId, from, to).Scan(
+		&report.Completed,
+		&report.CycleTime.Count,
+		pq.Array(&cycleTimes),
+		pq.Array(&leadTimes),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report.LeadTime.Count = report.Completed
+	report.CycleTime.P50, report.CycleTime.P85, report.CycleTime.P95 = percentiles(cycleTimes)
+	report.LeadTime.P50, report.LeadTime.P85, report.LeadTime.P95 = percentiles(leadTimes)
+
+	return report, nil
+}
+
+// Returns the number of the project's tasks completed in each week between
+// two days. The first and the last week only count the days in the range.
+func (s *Store) Throughput(projectId int, from types.Date, to types.Date) (*types.ThroughputReport, error) {
+	rows, err := s.db.Query("SELECT weeks.week::date, COUNT(tasks.id) "+
+		"FROM generate_series(date_trunc('week', $2::timestamp), $3::timestamp, interval '1 week') AS weeks(week) "+
+		"LEFT JOIN tasks ON tasks.projectId = $1 AND "+completedInRange+" "+
+		"AND tasks.completedAt >= weeks.week AND tasks.completedAt < weeks.week + interval '1 week' "+
+		"GROUP BY weeks.week ORDER BY weeks.week", projectId, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	report := &types.ThroughputReport{
+		From:  from,
+		To:    to,
+		Weeks: []types.ThroughputWeek{},
+	}
+
+	for rows.Next() {
+		var week types.ThroughputWeek
+		if err := rows.Scan(&week.Week, &week.Completed); err != nil {
+			return nil, err
+		}
+
+		report.Weeks = append(report.Weeks, week)
+	}
+
+	return report, nil
+}
+
+// Reads the 50th, 85th and 95th percentile from the result of percentile_cont,
+// which is NULL when there were no values
+func percentiles(values []sql.NullFloat64) (*float64, *float64, *float64) {
+	result := make([]*float64, 3)
+	for i := range result {
+		if i < len(values) && values[i].Valid {
+			result[i] = &values[i].Float64
+		}
+	}
+
+	return result[0], result[1], result[2]
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 50
+)
+
+type Handler struct {
+	store types.SearchStore
+}
+
+func NewHandler(store types.SearchStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.handleSearch).Methods(http.MethodGet)
+}
+
+// @Summary Full-text search
+// @Description Search task and project titles and descriptions, and user names and emails. Supports web search syntax: "quoted phrases", OR and -excluded words. Hits are grouped by entity type and ranked. Highlights are HTML: the text is escaped and matching terms are wrapped in <mark> tags.
+// @Tags Search
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Search terms"
+// @Param limit query int false "Maximum hits per entity type (default 10, max 50)"
+// @Success 200 {object} types.SearchResults
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /search [get]
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("q query parameter is required"))
+		return
+	}
+
+	limit := defaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			return
+		}
+
+		limit = min(value, maxLimit)
+	}
+
+	results, err := h.store.Search(query, limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, results)
+}
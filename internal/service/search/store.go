@@ -0,0 +1,95 @@
+package search
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+// ts_headline marks matches with control characters that plain text does not
+// contain. The excerpt is HTML escaped before they are turned into <mark> tags.
+const (
+	startSel = "\x02"
+	stopSel  = "\x03"
+)
+
+const headlineOptions = `StartSel="` + startSel + `", StopSel="` + stopSel + `", MaxFragments=2, MaxWords=20, MinWords=5`
+
+var marks = strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>")
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Runs the query against tasks, projects and users and returns at most
+// limit hits of each, best ranked first
+func (s *Store) Search(query string, limit int) (*types.SearchResults, error) {
+	results := &types.SearchResults{Query: query}
+	var err error
+
+	results.Tasks, err = s.searchHits("SELECT id, title, ts_rank(searchVector, q), "+
+		"ts_headline('english', title || ' ' || coalesce(descript, ''), q, $3) "+
+		"FROM tasks, websearch_to_tsquery('english', $1) q "+
+		"WHERE searchVector @@ q ORDER BY 3 DESC, id LIMIT $2", query, limit, headlineOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results.Projects, err = s.searchHits("SELECT id, title, ts_rank(searchVector, q), "+
+		"ts_headline('english', title || ' ' || coalesce(descript, ''), q, $3) "+
+		"FROM projects, websearch_to_tsquery('english', $1) q "+
+		"WHERE searchVector @@ q ORDER BY 3 DESC, id LIMIT $2", query, limit, headlineOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results.Users, err = s.searchHits("SELECT id, fullName, ts_rank(searchVector, q), "+
+		"ts_headline('simple', fullName || ' ' || email, q, $3) "+
+		"FROM users, websearch_to_tsquery('simple', $1) q "+
+		"WHERE searchVector @@ q ORDER BY 3 DESC, id LIMIT $2", query, limit, headlineOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *Store) searchHits(query string, args ...any) ([]types.SearchHit, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	hits := []types.SearchHit{}
+	for rows.Next() {
+		hit := types.SearchHit{}
+		if err := rows.Scan(&hit.ID, &hit.Title, &hit.Rank, &hit.Highlight); err != nil {
+			return nil, err
+		}
+
+		hit.Highlight = highlight(hit.Highlight)
+
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// Turns an excerpt from ts_headline into HTML that is safe to render
+func highlight(headline string) string {
+	return marks.Replace(html.EscapeString(headline))
+}
@@ -0,0 +1,21 @@
+package search
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"fix the \x02login\x03 page", "fix the <mark>login</mark> page"},
+		{"<script>alert(1)</script> \x02login\x03", "&lt;script&gt;alert(1)&lt;/script&gt; <mark>login</mark>"},
+		{"a \"quoted\" & 'single' \x02term\x03", "a &#34;quoted&#34; &amp; &#39;single&#39; <mark>term</mark>"},
+		{"<mark>fake</mark>", "&lt;mark&gt;fake&lt;/mark&gt;"},
+	}
+
+	for _, test := range tests {
+		if got := highlight(test.headline); got != test.want {
+			t.Errorf("highlight(%q) = %q, want %q", test.headline, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package sprints
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.SprintStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.SprintStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the sprint routes under the projects router, as sprints belong to a project
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/sprints", h.handleListSprints).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/sprints", h.handleCreateSprint).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/sprints/{sprintId}", h.handleGetSprintById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/sprints/{sprintId}", h.handleUpdateSprint).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/sprints/{sprintId}", h.handleDeleteSprint).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/sprints/{sprintId}/start", h.handleStartSprint).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/sprints/{sprintId}/complete", h.handleCompleteSprint).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/sprints/{sprintId}/tasks", h.handleListSprintTasks).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/sprints/{sprintId}/tasks/{taskId}", h.handleAddTask).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/sprints/{sprintId}/tasks/{taskId}", h.handleRemoveTask).Methods(http.MethodDelete)
+}
+
+// @Summary List project sprints
+// @Description Get the sprints of a project in the order they are scheduled, unscheduled sprints last
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {array} types.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints [get]
+func (h *Handler) handleListSprints(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	sprints, err := h.store.ListProjectSprints(project.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sprints)
+}
+
+// @Summary Create a sprint
+// @Description Plan a new sprint for a project. Dates are optional until the sprint is started.
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprint body types.SprintPayload true "Sprint details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints [post]
+func (h *Handler) handleCreateSprint(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateSprint(types.Sprint{
+		ProjectId: project.ID,
+		Name:      payload.Name,
+		Goal:      payload.Goal,
+		StartDate: payload.StartDate,
+		EndDate:   payload.EndDate,
+	})
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get sprint by ID
+// @Description Get a single sprint of a project
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Success 200 {object} types.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId} [get]
+func (h *Handler) handleGetSprintById(w http.ResponseWriter, r *http.Request) {
+	_, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sprint)
+}
+
+// @Summary Update a sprint
+// @Description Change the name, goal or dates of a sprint that is not closed
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Param sprint body types.SprintPayload true "Sprint details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId} [put]
+func (h *Handler) handleUpdateSprint(w http.ResponseWriter, r *http.Request) {
+	project, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateSprint(sprint.ID, types.Sprint{
+		Name:      payload.Name,
+		Goal:      payload.Goal,
+		StartDate: payload.StartDate,
+		EndDate:   payload.EndDate,
+	})
+
+	if err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a sprint
+// @Description Delete a planned sprint. Its tasks go back to the project's backlog. Active and closed sprints cannot be deleted.
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId} [delete]
+func (h *Handler) handleDeleteSprint(w http.ResponseWriter, r *http.Request) {
+	project, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteSprint(sprint.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary Start a sprint
+// @Description Make a planned sprint the project's active one. A project has at most one active sprint. A sprint without dates starts today and runs for two weeks.
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Success 200 {object} types.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId}/start [post]
+func (h *Handler) handleStartSprint(w http.ResponseWriter, r *http.Request) {
+	project, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	started, err := h.store.StartSprint(sprint.ID)
+	if err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, started)
+}
+
+// @Summary Complete a sprint
+// @Description Close the active sprint. Unfinished tasks are carried over to carry_over_to if given, otherwise to the project's next planned sprint, or to the backlog when there is none. The body is optional.
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Param sprint body types.CompleteSprintPayload false "Where to carry unfinished tasks over to"
+// @Success 200 {object} types.SprintCompletion
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId}/complete [post]
+func (h *Handler) handleCompleteSprint(w http.ResponseWriter, r *http.Request) {
+	project, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.CompleteSprintPayload
+	if err := utils.ParseJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	completion, err := h.store.CompleteSprint(sprint.ID, payload.CarryOverTo, auth.GetUserIdFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, completion)
+}
+
+// @Summary List sprint tasks
+// @Description Get the tasks in a sprint
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId}/tasks [get]
+func (h *Handler) handleListSprintTasks(w http.ResponseWriter, r *http.Request) {
+	_, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks, err := h.store.ListSprintTasks(sprint.ID, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tasks)
+}
+
+// @Summary Move a task into a sprint
+// @Description Move a task of the project into the sprint, from the backlog or another sprint. Tasks cannot be moved into or out of closed sprints.
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Param taskId path int true "Task ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId}/tasks/{taskId} [put]
+func (h *Handler) handleAddTask(w http.ResponseWriter, r *http.Request) {
+	sprint, task, ok := h.getSprintTask(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.AddTask(sprint.ID, task.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Added successfully"})
+}
+
+// @Summary Move a task out of a sprint
+// @Description Move a task of the sprint back to the project's backlog
+// @Tags Sprints
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param sprintId path int true "Sprint ID"
+// @Param taskId path int true "Task ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/sprints/{sprintId}/tasks/{taskId} [delete]
+func (h *Handler) handleRemoveTask(w http.ResponseWriter, r *http.Request) {
+	sprint, task, ok := h.getSprintTask(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RemoveTask(sprint.ID, task.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, sprintErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Removed successfully"})
+}
+
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request) (*types.Project, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return nil, false
+	}
+
+	return project, true
+}
+
+// Loads the project and the sprint from the path, making sure the sprint belongs to that project
+func (h *Handler) getSprint(w http.ResponseWriter, r *http.Request) (*types.Project, *types.Sprint, bool) {
+	project, ok := h.getProject(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	sprintId, _ := strconv.Atoi(mux.Vars(r)["sprintId"])
+
+	sprint, err := h.store.GetSprintById(sprintId)
+	if err != nil || sprint.ProjectId != project.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get sprint by id: sprint not found"))
+		return nil, nil, false
+	}
+
+	return project, sprint, true
+}
+
+// Loads the sprint and the task from the path and checks the caller may plan
+// the sprint. Only tasks of the sprint's own project can be moved into it.
+func (h *Handler) getSprintTask(w http.ResponseWriter, r *http.Request) (*types.Sprint, *types.Task, bool) {
+	project, sprint, ok := h.getSprint(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	taskId, _ := strconv.Atoi(mux.Vars(r)["taskId"])
+
+	task, err := h.taskStore.GetTaskById(taskId)
+	if err != nil || task.ProjectId != project.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: task not found in the sprint's project"))
+		return nil, nil, false
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageSprints, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return nil, nil, false
+	}
+
+	return sprint, task, true
+}
+
+func parsePayload(w http.ResponseWriter, r *http.Request) (*types.SprintPayload, bool) {
+	var payload types.SprintPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	if payload.StartDate != nil && payload.EndDate != nil && payload.EndDate.Before(payload.StartDate.Time) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("end date %s is before start date %s", payload.EndDate, payload.StartDate))
+		return nil, false
+	}
+
+	return &payload, true
+}
+
+func sprintErrorStatus(err error) int {
+	if errors.Is(err, types.ErrSprintNotFound) {
+		return http.StatusNotFound
+	}
+
+	if errors.Is(err, types.ErrSprintActive) || errors.Is(err, types.ErrSprintState) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
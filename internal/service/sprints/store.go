@@ -0,0 +1,421 @@
+package sprints
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoSprint expects
+const Columns = "id, projectId, name, goal, startDate, endDate, state, completedAt, createdAt, updatedAt"
+
+// Length of a sprint started without an end date, in days
+const defaultLength = 14
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Returns the sprints of a project in the order they are scheduled, unscheduled last
+func (s *Store) ListProjectSprints(projectId int) ([]types.Sprint, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM sprints WHERE projectId = $1 ORDER BY startDate NULLS LAST, id", projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sprints := []types.Sprint{}
+	for rows.Next() {
+		sprint, err := ScanRowIntoSprint(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		sprints = append(sprints, *sprint)
+	}
+
+	return sprints, nil
+}
+
+func (s *Store) GetSprintById(sprintId int) (*types.Sprint, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM sprints WHERE id = $1", sprintId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sprint := new(types.Sprint)
+	for rows.Next() {
+		sprint, err = ScanRowIntoSprint(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if sprint.ID == 0 {
+		return nil, fmt.Errorf("sprint not found")
+	}
+
+	return sprint, nil
+}
+
+func (s *Store) CreateSprint(sprint types.Sprint) error {
+	_, err := s.db.Exec("INSERT INTO sprints (projectId, name, goal, startDate, endDate) VALUES ($1, $2, $3, $4, $5)",
+		sprint.ProjectId, sprint.Name, sprint.Goal, sprint.StartDate, sprint.EndDate)
+
+	if err != nil {
+		return fmt.Errorf("failed to create sprint: %w", err)
+	}
+
+	return nil
+}
+
+// Changes the details of a sprint that is not closed yet
+func (s *Store) UpdateSprint(sprintId int, sprint types.Sprint) error {
+	result, err := s.db.Exec("UPDATE sprints SET name = $1, goal = $2, startDate = $3, endDate = $4, updatedAt = NOW() "+
+		"WHERE id = $5 AND state <> 'closed'", sprint.Name, sprint.Goal, sprint.StartDate, sprint.EndDate, sprintId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update sprint: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return fmt.Errorf("%w: closed sprints cannot be changed", types.ErrSprintState)
+	}
+
+	return nil
+}
+
+// Deletes a planned sprint, moving its tasks back to the backlog. Active and
+// closed sprints are kept, as their tasks' history refers to them.
+func (s *Store) DeleteSprint(sprintId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var state types.SprintState
+	err = tx.QueryRow("SELECT state FROM sprints WHERE id = $1 FOR UPDATE", sprintId).Scan(&state)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrSprintNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete sprint: %w", err)
+	}
+
+	if state != types.SprintPlanned {
+		return fmt.Errorf("%w: only planned sprints can be deleted", types.ErrSprintState)
+	}
+
+	// Its tasks go back to the backlog
+	taskIds, err := queryTaskIds(tx, "SELECT id FROM tasks WHERE sprintId = $1 ORDER BY id", sprintId)
+	if err != nil {
+		return fmt.Errorf("failed to delete sprint: %w", err)
+	}
+
+	if err := moveTasks(tx, taskIds, nil, actorId); err != nil {
+		return fmt.Errorf("failed to delete sprint: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM sprints WHERE id = $1", sprintId); err != nil {
+		return fmt.Errorf("failed to delete sprint: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Makes a planned sprint the project's active one. A sprint without dates
+// starts today and runs for two weeks.
+func (s *Store) StartSprint(sprintId int) (*types.Sprint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var state types.SprintState
+	if err := tx.QueryRow("SELECT state FROM sprints WHERE id = $1 FOR UPDATE", sprintId).Scan(&state); err != nil {
+		return nil, fmt.Errorf("failed to start sprint: %w", err)
+	}
+
+	if state != types.SprintPlanned {
+		return nil, fmt.Errorf("%w: only planned sprints can be started", types.ErrSprintState)
+	}
+
+	sprint, err := querySprint(tx, "UPDATE sprints SET state = 'active', updatedAt = NOW(), "+
+		"startDate = COALESCE(startDate, LEAST(CURRENT_DATE, endDate)), "+
+		"endDate = COALESCE(endDate, COALESCE(startDate, CURRENT_DATE) + $2::int - 1) "+
+		"WHERE id = $1 RETURNING "+Columns, sprintId, defaultLength)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, types.ErrSprintActive
+		}
+
+		return nil, fmt.Errorf("failed to start sprint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return sprint, nil
+}
+
+// Closes the active sprint. Its unfinished tasks are carried over to the
+// sprint carryOverTo, which must be planned, or when nil to the project's
+// next planned sprint, falling back to the backlog if there is none.
+func (s *Store) CompleteSprint(sprintId int, carryOverTo *int, actorId int) (*types.SprintCompletion, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	sprint, err := querySprint(tx, "SELECT "+Columns+" FROM sprints WHERE id = $1 FOR UPDATE", sprintId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete sprint: %w", err)
+	}
+
+	if sprint.State != types.SprintActive {
+		return nil, fmt.Errorf("%w: only the active sprint can be completed", types.ErrSprintState)
+	}
+
+	if carryOverTo != nil {
+		var state types.SprintState
+		err = tx.QueryRow("SELECT state FROM sprints WHERE id = $1 AND projectId = $2 FOR SHARE",
+			*carryOverTo, sprint.ProjectId).Scan(&state)
+
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && state != types.SprintPlanned) {
+			return nil, fmt.Errorf("%w: tasks can only be carried over to a planned sprint of the project", types.ErrSprintState)
+		}
+	} else {
+		err = tx.QueryRow("SELECT id FROM sprints WHERE projectId = $1 AND state = 'planned' "+
+			"ORDER BY startDate NULLS LAST, id LIMIT 1 FOR SHARE", sprint.ProjectId).Scan(&carryOverTo)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete sprint: %w", err)
+	}
+
+	completion := &types.SprintCompletion{CarriedOverTo: carryOverTo}
+
+	err = tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE sprintId = $1 AND "+workflows.CategoryColumn+" = $2",
+		sprintId, types.Done).Scan(&completion.Completed)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete sprint: %w", err)
+	}
+
+	taskIds, err := queryTaskIds(tx, "SELECT id FROM tasks WHERE sprintId = $1 AND "+workflows.CategoryColumn+" IS DISTINCT FROM $2 "+
+		"ORDER BY id", sprintId, types.Done)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to carry over tasks: %w", err)
+	}
+
+	if err := moveTasks(tx, taskIds, carryOverTo, actorId); err != nil {
+		return nil, fmt.Errorf("failed to carry over tasks: %w", err)
+	}
+
+	completion.CarriedOver = len(taskIds)
+
+	sprint, err = querySprint(tx, "UPDATE sprints SET state = 'closed', completedAt = NOW(), updatedAt = NOW() "+
+		"WHERE id = $1 RETURNING "+Columns, sprintId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete sprint: %w", err)
+	}
+
+	completion.Sprint = *sprint
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return completion, nil
+}
+
+func (s *Store) ListSprintTasks(sprintId int, params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: tasks.Columns,
+		From:   "tasks",
+		Where:  "sprintId = $1",
+		Args:   []any{sprintId},
+	}, tasks.Keyset, params, tasks.ScanRowIntoTask)
+}
+
+// Moves a task into the sprint from the backlog or another sprint. Neither
+// sprint may be closed.
+func (s *Store) AddTask(sprintId int, taskId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := checkOpen(tx, "SELECT state FROM sprints WHERE id = $1 FOR SHARE", sprintId); err != nil {
+		return err
+	}
+
+	if err := checkOpen(tx, "SELECT sprints.state FROM tasks JOIN sprints ON sprints.id = tasks.sprintId "+
+		"WHERE tasks.id = $1 FOR UPDATE OF tasks", taskId); err != nil {
+		return err
+	}
+
+	if err := moveTasks(tx, []int{taskId}, &sprintId, actorId); err != nil {
+		return fmt.Errorf("failed to add task to sprint: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Moves a task of the sprint back to the backlog
+func (s *Store) RemoveTask(sprintId int, taskId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := checkOpen(tx, "SELECT state FROM sprints WHERE id = $1 FOR SHARE", sprintId); err != nil {
+		return err
+	}
+
+	taskIds, err := queryTaskIds(tx, "SELECT id FROM tasks WHERE id = $1 AND sprintId = $2", taskId, sprintId)
+	if err != nil {
+		return fmt.Errorf("failed to remove task from sprint: %w", err)
+	}
+
+	if err := moveTasks(tx, taskIds, nil, actorId); err != nil {
+		return fmt.Errorf("failed to remove task from sprint: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Moves the tasks into a sprint, or to the backlog when sprintId is nil. Each
+// move bumps the task's updatedAt and is recorded in its audit trail.
+func moveTasks(tx *sql.Tx, taskIds []int, sprintId *int, actorId int) error {
+	for _, taskId := range taskIds {
+		err := tasks.Track(tx, taskId, actorId, func(*types.Task) error {
+			_, err := tx.Exec("UPDATE tasks SET sprintId = $1 WHERE id = $2", sprintId, taskId)
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func queryTaskIds(tx *sql.Tx, query string, args ...any) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	taskIds := []int{}
+	for rows.Next() {
+		var taskId int
+		if err := rows.Scan(&taskId); err != nil {
+			return nil, err
+		}
+
+		taskIds = append(taskIds, taskId)
+	}
+
+	return taskIds, rows.Err()
+}
+
+// Fails with ErrSprintState when the sprint the query selects the state of is
+// closed. A query that selects no sprint passes.
+func checkOpen(tx *sql.Tx, query string, id int) error {
+	var state types.SprintState
+	err := tx.QueryRow(query, id).Scan(&state)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if state == types.SprintClosed {
+		return fmt.Errorf("%w: tasks cannot be moved into or out of a closed sprint", types.ErrSprintState)
+	}
+
+	return nil
+}
+
+func querySprint(tx *sql.Tx, query string, args ...any) (*types.Sprint, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("sprint not found")
+	}
+
+	return ScanRowIntoSprint(rows)
+}
+
+func ScanRowIntoSprint(rows *sql.Rows) (*types.Sprint, error) {
+	sprint := new(types.Sprint)
+
+	err := rows.Scan(
+		&sprint.ID,
+		&sprint.ProjectId,
+		&sprint.Name,
+		&sprint.Goal,
+		&sprint.StartDate,
+		&sprint.EndDate,
+		&sprint.State,
+		&sprint.CompletedAt,
+		&sprint.CreatedAt,
+		&sprint.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sprint, nil
+}
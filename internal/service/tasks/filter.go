@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+var (
+	statusCategories = []types.StatusCategory{types.Todo, types.InProgress, types.Done}
+	taskTypes        = []types.TaskType{types.Low, types.Medium, types.High}
+)
+
+// Reads a task search from query parameters. List parameters take comma
+// separated values and are negated with a leading "!", e.g.
+// status=!done&assignee=3,7&created_after=2024-07-01
+func ParseTaskFilter(query url.Values) (types.TaskFilter, error) {
+	filter := types.TaskFilter{}
+	var err error
+
+	filter.Title = parseSet(query.Get("title"), func(value string) (string, error) {
+		return value, nil
+	}, &err)
+
+	filter.Status = parseSet(query.Get("status"), func(value string) (string, error) {
+		return value, nil
+	}, &err)
+
+	filter.Category = parseSet(query.Get("category"), func(value string) (types.StatusCategory, error) {
+		return parseEnum(value, statusCategories)
+	}, &err)
+
+	filter.Priority = parseSet(query.Get("priority"), func(value string) (types.TaskType, error) {
+		return parseEnum(value, taskTypes)
+	}, &err)
+
+	filter.Assignee = parseSet(query.Get("assignee"), strconv.Atoi, &err)
+	filter.Project = parseSet(query.Get("project"), strconv.Atoi, &err)
+	filter.Sprint = parseSet(query.Get("sprint"), strconv.Atoi, &err)
+	filter.Milestone = parseSet(query.Get("milestone"), strconv.Atoi, &err)
+	filter.Team = parseSet(query.Get("team"), strconv.Atoi, &err)
+
+	filter.Label = parseSet(query.Get("label"), func(value string) (string, error) {
+		return value, nil
+	}, &err)
+
+	filter.CustomFields = parseCustomFields(query, &err)
+
+	filter.CreatedAfter = parseDate(query.Get("created_after"), &err)
+	filter.CreatedBefore = parseDate(query.Get("created_before"), &err)
+	filter.UpdatedAfter = parseDate(query.Get("updated_after"), &err)
+	filter.UpdatedBefore = parseDate(query.Get("updated_before"), &err)
+	filter.DueAfter = parseDate(query.Get("due_after"), &err)
+	filter.DueBefore = parseDate(query.Get("due_before"), &err)
+
+	return filter, err
+}
+
+// Parsers record the first failure in err and keep going, so that a
+// filter can be read field by field without checking after every call
+func parseSet[T any](raw string, parse func(string) (T, error), err *error) types.FilterSet[T] {
+	set := types.FilterSet[T]{}
+	if raw == "" {
+		return set
+	}
+
+	raw, set.Negate = strings.CutPrefix(raw, "!")
+
+	for _, part := range strings.Split(raw, ",") {
+		value, parseErr := parse(strings.TrimSpace(part))
+		if parseErr != nil {
+			if *err == nil {
+				*err = fmt.Errorf("invalid filter value %q: %v", part, parseErr)
+			}
+
+			continue
+		}
+
+		set.Values = append(set.Values, value)
+	}
+
+	return set
+}
+
+// Reads custom_fields.<name>=a,b and, for number and date fields,
+// custom_fields.<name>.min and custom_fields.<name>.max. The values are
+// checked against the field's type once the fields are known.
+func parseCustomFields(query url.Values, err *error) map[string]types.FieldFilter {
+	filters := map[string]types.FieldFilter{}
+
+	for key := range query {
+		name, ok := strings.CutPrefix(key, customFieldPrefix)
+		if !ok {
+			continue
+		}
+
+		value := query.Get(key)
+		if minName, ok := strings.CutSuffix(name, ".min"); ok {
+			filter := filters[minName]
+			filter.Min = value
+			filters[minName] = filter
+		} else if maxName, ok := strings.CutSuffix(name, ".max"); ok {
+			filter := filters[maxName]
+			filter.Max = value
+			filters[maxName] = filter
+		} else {
+			filter := filters[name]
+			filter.Values = parseSet(value, func(value string) (string, error) {
+				return value, nil
+			}, err)
+			filters[name] = filter
+		}
+	}
+
+	return filters
+}
+
+func parseEnum[T ~string](value string, allowed []T) (T, error) {
+	if !slices.Contains(allowed, T(value)) {
+		return "", fmt.Errorf("expected one of %v", allowed)
+	}
+
+	return T(value), nil
+}
+
+// Accepts either a full RFC 3339 timestamp or a plain date. Timestamps are
+// stored in UTC without a zone, so the result is converted to UTC.
+func parseDate(raw string, err *error) *time.Time {
+	if raw == "" {
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if date, parseErr := time.Parse(layout, raw); parseErr == nil {
+			date = date.UTC()
+			return &date
+		}
+	}
+
+	if *err == nil {
+		*err = fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", raw)
+	}
+
+	return nil
+}
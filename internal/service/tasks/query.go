@@ -0,0 +1,215 @@
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/service/fields"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// queryBuilder collects AND-ed predicates over the tasks table. Column names
+// only ever come from the code; every user supplied value is bound as a
+// numbered parameter.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// Matches the column against any of the values, or none of them when negated
+func in[T any](b *queryBuilder, column string, set types.FilterSet[T]) {
+	if len(set.Values) == 0 {
+		return
+	}
+
+	condition := fmt.Sprintf("%s = ANY(%s)", column, b.arg(pq.Array(set.Values)))
+	if set.Negate {
+		condition = "NOT " + condition
+	}
+
+	b.where(condition)
+}
+
+// Matches the column against any of the substrings, case insensitively
+func (b *queryBuilder) contains(column string, set types.FilterSet[string]) {
+	if len(set.Values) == 0 {
+		return
+	}
+
+	patterns := make([]string, len(set.Values))
+	for i, value := range set.Values {
+		patterns[i] = "%" + db.EscapeLike(value) + "%"
+	}
+
+	condition := fmt.Sprintf("%s ILIKE ANY(%s)", column, b.arg(pq.Array(patterns)))
+	if set.Negate {
+		condition = "NOT " + condition
+	}
+
+	b.where(condition)
+}
+
+// Matches array columns sharing any of the values, or none of them when negated
+func (b *queryBuilder) overlaps(column string, set types.FilterSet[string]) {
+	if len(set.Values) == 0 {
+		return
+	}
+
+	condition := fmt.Sprintf("%s && %s", column, b.arg(pq.Array(set.Values)))
+	if set.Negate {
+		condition = "NOT " + condition
+	}
+
+	b.where(condition)
+}
+
+// Matches tasks assigned to a member of any of the teams, or of none of them when negated
+func (b *queryBuilder) assignedToTeam(set types.FilterSet[int]) {
+	if len(set.Values) == 0 {
+		return
+	}
+
+	condition := fmt.Sprintf("userId IN (SELECT userId FROM team_members WHERE teamId = ANY(%s))", b.arg(pq.Array(set.Values)))
+	if set.Negate {
+		condition = "NOT " + condition
+	}
+
+	b.where(condition)
+}
+
+func (b *queryBuilder) compare(column string, op string, value any) {
+	b.where(fmt.Sprintf("%s %s %s", column, op, b.arg(value)))
+}
+
+func (b *queryBuilder) build() (string, []any) {
+	return strings.Join(b.conditions, " AND "), b.args
+}
+
+// Builds the predicate of a task search. Custom fields are looked up by name
+// in projectFields, the fields of the project the search is limited to.
+func buildTaskFilter(filter types.TaskFilter, projectFields []types.CustomField) (string, []any, error) {
+	b := &queryBuilder{}
+
+	b.contains("title", filter.Title)
+	in(b, "status", filter.Status)
+	in(b, workflows.CategoryColumn, filter.Category)
+	in(b, "taskType", filter.Priority)
+	in(b, "userId", filter.Assignee)
+	in(b, "projectId", filter.Project)
+	in(b, "COALESCE(sprintId, 0)", filter.Sprint)
+	in(b, "COALESCE(milestoneId, 0)", filter.Milestone)
+	b.assignedToTeam(filter.Team)
+	b.overlaps(LabelsColumn, filter.Label)
+
+	if filter.CreatedAfter != nil {
+		b.compare("createdAt", ">=", *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		b.compare("createdAt", "<", *filter.CreatedBefore)
+	}
+
+	if filter.UpdatedAfter != nil {
+		b.compare("updatedAt", ">=", *filter.UpdatedAfter)
+	}
+
+	if filter.UpdatedBefore != nil {
+		b.compare("updatedAt", "<", *filter.UpdatedBefore)
+	}
+
+	if filter.DueAfter != nil {
+		b.compare("dueDate", ">=", *filter.DueAfter)
+	}
+
+	if filter.DueBefore != nil {
+		b.compare("dueDate", "<", *filter.DueBefore)
+	}
+
+	if err := b.customFields(filter.CustomFields, projectFields); err != nil {
+		return "", nil, err
+	}
+
+	where, args := b.build()
+	return where, args, nil
+}
+
+// Array casts that let the raw values of a custom field filter be compared
+// with the column holding the field's values
+var valueCasts = map[types.CustomFieldType]string{
+	types.FieldNumber: "::double precision[]",
+	types.FieldDate:   "::date[]",
+	types.FieldUser:   "::int[]",
+}
+
+func (b *queryBuilder) customFields(filters map[string]types.FieldFilter, projectFields []types.CustomField) error {
+	byName := map[string]types.CustomField{}
+	for _, field := range projectFields {
+		byName[field.Name] = field
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	for _, name := range names {
+		filter := filters[name]
+
+		field, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%w: the project has no custom field %q", types.ErrInvalidListParams, name)
+		}
+
+		column := fields.ValueExpression(field)
+
+		if len(filter.Values.Values) > 0 {
+			for _, value := range filter.Values.Values {
+				if _, err := fields.ParseValue(field, value); err != nil {
+					return fmt.Errorf("%w: %v", types.ErrInvalidListParams, err)
+				}
+			}
+
+			// Tasks without a value do not match a set, but do match its negation
+			condition := fmt.Sprintf("COALESCE(%s = ANY(%s%s), FALSE)", column, b.arg(pq.Array(filter.Values.Values)), valueCasts[field.Type])
+			if filter.Values.Negate {
+				condition = "NOT " + condition
+			}
+
+			b.where(condition)
+		}
+
+		bounds := []struct{ op, raw string }{{">=", filter.Min}, {"<=", filter.Max}}
+		for _, bound := range bounds {
+			if bound.raw == "" {
+				continue
+			}
+
+			if field.Type != types.FieldNumber && field.Type != types.FieldDate {
+				return fmt.Errorf("%w: only number and date fields have a range, %s is a %s field", types.ErrInvalidListParams, name, field.Type)
+			}
+
+			value, err := fields.ParseValue(field, bound.raw)
+			if err != nil {
+				return fmt.Errorf("%w: %v", types.ErrInvalidListParams, err)
+			}
+
+			b.compare(column, bound.op, value)
+		}
+	}
+
+	return nil
+}
@@ -1,10 +1,15 @@
 package tasks
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/4lerman/pm_service/internal/jql"
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/pkg/storage"
 	"github.com/4lerman/pm_service/types"
 	"github.com/4lerman/pm_service/utils"
 	"github.com/go-playground/validator/v10"
@@ -12,22 +17,36 @@ import (
 )
 
 type Handler struct {
-	store types.TaskStore
+	store          types.TaskStore
+	projectStore   types.ProjectStore
+	workflowStore  types.WorkflowStore
+	milestoneStore types.MilestoneStore
+	files          storage.Storage
 }
 
-func NewHandler(store types.TaskStore) *Handler {
+func NewHandler(store types.TaskStore, projectStore types.ProjectStore, workflowStore types.WorkflowStore, milestoneStore types.MilestoneStore, files storage.Storage) *Handler {
 	return &Handler{
-		store: store,
+		store:          store,
+		projectStore:   projectStore,
+		workflowStore:  workflowStore,
+		milestoneStore: milestoneStore,
+		files:          files,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("", h.handleListTasks).Methods(http.MethodGet)
 	router.HandleFunc("", h.handleCreateTask).Methods(http.MethodPost)
-	router.HandleFunc("/search", h.handleGetTaskByQuery).Methods(http.MethodGet)
+	router.HandleFunc("/search", h.handleSearchTasks).Methods(http.MethodGet)
+	router.HandleFunc("/query", h.handleQueryTasks).Methods(http.MethodGet)
+	router.HandleFunc("/overdue", h.handleListOverdueTasks).Methods(http.MethodGet)
 	router.HandleFunc("/{id}", h.handleGetTaskById).Methods(http.MethodGet)
 	router.HandleFunc("/{id}", h.handleUpdateTask).Methods(http.MethodPut)
 	router.HandleFunc("/{id}", h.handleDeleteTask).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/transition", h.handleTransitionTask).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/move", h.handleMoveTask).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/children", h.handleGetTaskChildren).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/tree", h.handleGetTaskTree).Methods(http.MethodGet)
 }
 
 // @Summary List all tasks
@@ -35,27 +54,42 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 // @Tags Tasks
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} types.Task
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /tasks [get]
 func (h *Handler) handleListTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.store.ListTasks()
+	params, err := utils.ParseListParams(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks, err := h.store.ListTasks(params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, tasks)
 }
 
 // @Summary Create a new task
-// @Description Create a new task with the given details
+// @Description Create a new task with the given details. The task starts in the first state of its project's workflow.
+// @Description Set parent_id to create it as a subtask of another task in the same project. A task cannot be created while the first column is at its WIP limit.
 // @Tags Tasks
 // @Accept  json
 // @Produce  json
 // @Param task body types.CreateTaskPayload true "Task details"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /tasks [post]
 func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	var payload types.CreateTaskPayload
@@ -70,17 +104,54 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.store.CreateTask(types.Task{
-		Title:        payload.Title,
-		Descript:     payload.Descript,
-		TaskType:     payload.TaskType,
-		TaskPriority: payload.TaskPriority,
-		UserId:       payload.UserId,
-		ProjectId:    payload.ProjectId,
-	})
+	project, err := h.projectStore.GetProjectById(payload.ProjectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.CreateTask, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.validateAssignee(payload.ProjectId, payload.UserId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.validateParent(payload.ProjectId, payload.ParentId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.validateMilestone(payload.ProjectId, payload.MilestoneId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validateDates(payload.StartDate, payload.DueDate); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.store.CreateTask(types.Task{
+		Title:     payload.Title,
+		Descript:  payload.Descript,
+		TaskType:  payload.TaskType,
+		UserId:    payload.UserId,
+		ProjectId: payload.ProjectId,
+		ParentId:  payload.ParentId,
+		StartDate: payload.StartDate,
+		DueDate:   payload.DueDate,
+
+		OriginalEstimate:  payload.OriginalEstimate,
+		RemainingEstimate: payload.RemainingEstimate,
+		MilestoneId:       payload.MilestoneId,
+	}, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, taskErrorStatus(err), err)
 		return
 	}
 
@@ -96,6 +167,7 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} types.Task
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /tasks/{id} [get]
 func (h *Handler) handleGetTaskById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -118,7 +190,8 @@ func (h *Handler) handleGetTaskById(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update task details
-// @Description Update task details by ID
+// @Description Update task details by ID. The status is changed with a transition; a task moved to a project without a state of the same name starts in that project's first state.
+// @Description A task with subtasks cannot move to another project. A moved task loses its labels and custom field values and counts towards the WIP limit of its new column.
 // @Tags Tasks
 // @Accept  json
 // @Produce  json
@@ -126,8 +199,11 @@ func (h *Handler) handleGetTaskById(w http.ResponseWriter, r *http.Request) {
 // @Param task body types.UpdateTaskPayload true "Task details"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /tasks/{id} [put]
 func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -140,6 +216,17 @@ func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	taskId, _ := strconv.Atoi(id)
 
+	task, err := h.store.GetTaskById(taskId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return
+	}
+
+	if err := h.authorizeTask(r, policy.UpdateTask, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	var payload types.UpdateTaskPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
@@ -152,17 +239,56 @@ func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.store.UpdateTask(taskId, types.Task{
-		Title:        payload.Title,
-		Descript:     payload.Descript,
-		TaskType:     payload.TaskType,
-		TaskPriority: payload.TaskPriority,
-		UserId:       payload.UserId,
-		ProjectId:    payload.ProjectId,
-	})
+	if payload.ProjectId != task.ProjectId {
+		project, err := h.projectStore.GetProjectById(payload.ProjectId)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("failed to get project by id: %v", err))
+			return
+		}
+
+		if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.CreateTask, project, policy.Resource{}); err != nil {
+			utils.WriteError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if err := h.validateAssignee(payload.ProjectId, payload.UserId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.validateParent(payload.ProjectId, payload.ParentId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.validateMilestone(payload.ProjectId, payload.MilestoneId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validateDates(payload.StartDate, payload.DueDate); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.store.UpdateTask(taskId, types.Task{
+		Title:     payload.Title,
+		Descript:  payload.Descript,
+		TaskType:  payload.TaskType,
+		UserId:    payload.UserId,
+		ProjectId: payload.ProjectId,
+		ParentId:  payload.ParentId,
+		StartDate: payload.StartDate,
+		DueDate:   payload.DueDate,
+
+		OriginalEstimate:  payload.OriginalEstimate,
+		RemainingEstimate: payload.RemainingEstimate,
+		MilestoneId:       payload.MilestoneId,
+	}, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, taskErrorStatus(err), err)
 		return
 	}
 
@@ -170,15 +296,17 @@ func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Delete task by ID
-// @Description Delete a task by its ID
+// @Description Delete a task by its ID, together with the content of its attachments
 // @Tags Tasks
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Task ID"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /tasks/{id} [delete]
 func (h *Handler) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -191,57 +319,466 @@ func (h *Handler) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 
 	taskId, _ := strconv.Atoi(id)
 
-	if err := h.store.DeleteTask(taskId); err != nil {
+	task, err := h.store.GetTaskById(taskId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return
+	}
+
+	if err := h.authorizeTask(r, policy.DeleteTask, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	keys, err := h.store.DeleteTask(taskId, auth.GetUserIdFromContext(r.Context()))
+	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	storage.DeleteAll(r.Context(), h.files, keys)
+
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
 }
 
-// @Summary Search tasks by query
-// @Description Search tasks by title, status, priority, assignee, or project
+// @Summary Transition task
+// @Description Move a task to another state of its project's workflow. Only the transitions configured for the project are allowed.
+// @Description A task cannot be done while it has open subtasks or is blocked, nor reopened while its parent is done, and cannot enter a state whose WIP limit is reached.
 // @Tags Tasks
 // @Accept  json
 // @Produce  json
-// @Param title query string false "Task title"
-// @Param status query string false "Task status"
-// @Param priority query string false "Task priority"
-// @Param assignee query string false "Task assignee"
-// @Param project query string false "Project ID"
-// @Success 200 {array} types.Task
+// @Param id path int true "Task ID"
+// @Param transition body types.TransitionTaskPayload true "Target status"
+// @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
-// @Router /tasks/search [get]
-func (h *Handler) handleGetTaskByQuery(w http.ResponseWriter, r *http.Request) {
-	queryParams := r.URL.Query()
-	var queryType, query string
-
-	if title := queryParams.Get("title"); title != "" {
-		queryType = "title"
-		query = title
-	} else if status := queryParams.Get("status"); status != "" {
-		queryType = "status"
-		query = status
-	} else if priority := queryParams.Get("priority"); priority != "" {
-		queryType = "priority"
-		query = priority
-	} else if assignee := queryParams.Get("assignee"); assignee != "" {
-		queryType = "assignee"
-		query = assignee
-	} else if project := queryParams.Get("project"); project != "" {
-		queryType = "project"
-		query = project
-	} else {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid query parameters"))
-		return
-	}
-
-	tasks_list, err := h.store.GetTasksByQuery(queryType, query)
+// @Security BearerAuth
+// @Router /tasks/{id}/transition [post]
+func (h *Handler) handleTransitionTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	task, err := h.store.GetTaskById(taskId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return
+	}
+
+	if err := h.authorizeTask(r, policy.UpdateTask, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.TransitionTaskPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	workflow, err := h.workflowStore.GetWorkflow(task.ProjectId)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if _, ok := workflow.State(payload.Status); !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", payload.Status))
+		return
+	}
+
+	if err := h.store.TransitionTask(taskId, task.Status, payload.Status, auth.GetUserIdFromContext(r.Context())); err != nil {
+		if errors.Is(err, types.ErrIllegalTransition) {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("%v, allowed from %q: %v", err, task.Status, workflow.Next(task.Status)))
+			return
+		}
+
+		utils.WriteError(w, taskErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Transitioned successfully"})
+}
+
+// @Summary Move task on the board
+// @Description Reorder a task on its project's board, optionally moving it to another column. The task is placed right below after_id and/or right above before_id,
+// @Description which must be in the target column; without either it goes to the bottom of the column. Moving to another column is a transition, so only the
+// @Description transitions configured for the project are allowed and the target column's WIP limit must not be exceeded.
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param move body types.MoveTaskPayload true "Target column and neighbours"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/move [post]
+func (h *Handler) handleMoveTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	task, err := h.store.GetTaskById(taskId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return
+	}
+
+	if err := h.authorizeTask(r, policy.UpdateTask, task); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.MoveTaskPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	workflow, err := h.workflowStore.GetWorkflow(task.ProjectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, ok := workflow.State(payload.Status); payload.Status != "" && !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", payload.Status))
+		return
+	}
+
+	move := types.TaskMove{
+		Status:   payload.Status,
+		AfterId:  payload.AfterId,
+		BeforeId: payload.BeforeId,
+	}
+
+	if err := h.store.MoveTask(taskId, move, auth.GetUserIdFromContext(r.Context())); err != nil {
+		if errors.Is(err, types.ErrIllegalTransition) {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("%v, allowed from %q: %v", err, task.Status, workflow.Next(task.Status)))
+			return
+		}
+
+		utils.WriteError(w, taskErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Moved successfully"})
+}
+
+// @Summary List overdue tasks
+// @Description Get the tasks whose due date has passed and that are not done yet
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/overdue [get]
+func (h *Handler) handleListOverdueTasks(w http.ResponseWriter, r *http.Request) {
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks_list, err := h.store.ListOverdueTasks(params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tasks_list)
+}
+
+// @Summary List subtasks
+// @Description Get the direct subtasks of a task
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/children [get]
+func (h *Handler) handleGetTaskChildren(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	if _, err := h.store.GetTaskById(taskId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	children, err := h.store.GetTaskChildren(taskId, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, children)
+}
+
+// @Summary Get task tree
+// @Description Get a task with all of its subtasks, nested. Progress is the percentage of completion: 100 or 0 for a task without subtasks, depending on whether it is done, and the average of its subtasks otherwise.
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {object} types.TaskTree
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/tree [get]
+func (h *Handler) handleGetTaskTree(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	tree, err := h.store.GetTaskTree(taskId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task tree: %v", err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tree)
+}
+
+// @Summary Search tasks
+// @Description Search tasks by any combination of filters. List filters take comma separated values and are negated with a leading "!", e.g. status=!done&assignee=3,7
+// @Description Within a single project, custom fields filter as custom_fields.<name>=a,b, with custom_fields.<name>.min and .max for number and date fields, and sort as e.g. sort=-custom_fields.<name>.
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param title query string false "Substrings of the task title"
+// @Param status query string false "Workflow state names"
+// @Param category query string false "Status categories: todo, in_progress, done"
+// @Param priority query string false "Task priorities"
+// @Param assignee query string false "Assignee IDs"
+// @Param project query string false "Project IDs"
+// @Param sprint query string false "Sprint IDs; 0 matches tasks in the backlog"
+// @Param milestone query string false "Milestone IDs; 0 matches tasks without a milestone"
+// @Param team query string false "Team IDs; matches tasks assigned to a member of the teams"
+// @Param label query string false "Label names; tasks with any of them match"
+// @Param created_after query string false "Created at or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_before query string false "Created before (YYYY-MM-DD or RFC 3339)"
+// @Param updated_after query string false "Updated at or after (YYYY-MM-DD or RFC 3339)"
+// @Param updated_before query string false "Updated before (YYYY-MM-DD or RFC 3339)"
+// @Param due_after query string false "Due on or after (YYYY-MM-DD)"
+// @Param due_before query string false "Due before (YYYY-MM-DD)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/search [get]
+func (h *Handler) handleSearchTasks(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseTaskFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks_list, err := h.store.SearchTasks(filter, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
 	utils.WriteJSON(w, http.StatusOK, tasks_list)
 }
+
+// @Summary Query tasks
+// @Description Run a task query such as: project = 4 AND status != done AND assignee in (3,7) ORDER BY updated_at DESC.
+// @Description Fields: id, title, project, assignee, parent, sprint, milestone, status, category (todo, in_progress, done), priority, start_date, due_date, completed_at, created_at, updated_at. Operators: = != < <= > >= ~ !~ IN, NOT IN, combined with AND, OR, NOT and parentheses.
+// @Description parent, sprint, milestone, start_date, due_date and completed_at can be tested with IS EMPTY and IS NOT EMPTY; != and NOT IN match them when empty.
+// @Description Dates are YYYY-MM-DD or RFC 3339, e.g. due_date < 2024-06-01T12:00:00+05:00, and need no quotes.
+// @Description currentUser() stands for the authenticated user. The sort parameter overrides ORDER BY.
+// @Tags Tasks
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Task query"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
+// @Failure 400 {object} map[string]any
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/query [get]
+func (h *Handler) handleQueryTasks(w http.ResponseWriter, r *http.Request) {
+	opts := jql.Options{}
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		opts.CurrentUserId = user.ID
+	}
+
+	query, err := jql.Compile(r.URL.Query().Get("q"), opts)
+	if err != nil {
+		var queryErr *jql.Error
+		if errors.As(err, &queryErr) {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]any{"error": queryErr.Error(), "position": queryErr.Pos})
+			return
+		}
+
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tasks_list, err := h.store.QueryTasks(*query, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tasks_list)
+}
+
+// Checks the action against the task's assignee and the manager of its project
+func (h *Handler) authorizeTask(r *http.Request, action policy.Action, task *types.Task) error {
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	return policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), action, project, policy.Resource{AssigneeId: task.UserId})
+}
+
+// Tasks can only be assigned to members of their project who are not mere viewers
+func (h *Handler) validateAssignee(projectId int, userId int) error {
+	member, err := h.projectStore.GetProjectMember(projectId, userId)
+	if err != nil {
+		return fmt.Errorf("user %d is not a member of project %d", userId, projectId)
+	}
+
+	if member.ProjectRole == types.Viewer {
+		return fmt.Errorf("user %d is a viewer of project %d and cannot be assigned tasks", userId, projectId)
+	}
+
+	return nil
+}
+
+// Subtasks must belong to an existing task of the same project
+func (h *Handler) validateParent(projectId int, parentId *int) error {
+	if parentId == nil {
+		return nil
+	}
+
+	parent, err := h.store.GetTaskById(*parentId)
+	if err != nil {
+		return fmt.Errorf("%w: task %d not found", types.ErrInvalidParent, *parentId)
+	}
+
+	if parent.ProjectId != projectId {
+		return fmt.Errorf("%w: task %d belongs to another project", types.ErrInvalidParent, *parentId)
+	}
+
+	return nil
+}
+
+// A task can only be planned for a milestone of its own project
+func (h *Handler) validateMilestone(projectId int, milestoneId *int) error {
+	if milestoneId == nil {
+		return nil
+	}
+
+	milestone, err := h.milestoneStore.GetMilestoneById(*milestoneId)
+	if err != nil || milestone.ProjectId != projectId {
+		return fmt.Errorf("milestone %d not found in project %d", *milestoneId, projectId)
+	}
+
+	return nil
+}
+
+// A task cannot be due before it starts
+func validateDates(startDate *types.Date, dueDate *types.Date) error {
+	if startDate != nil && dueDate != nil && dueDate.Before(startDate.Time) {
+		return fmt.Errorf("due date %s is before start date %s", dueDate, startDate)
+	}
+
+	return nil
+}
+
+// Maps the errors of the checks the store runs on task changes to their status codes
+func taskErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrInvalidParent), errors.Is(err, types.ErrInvalidMove):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrParentDone), errors.Is(err, types.ErrOpenSubtasks), errors.Is(err, types.ErrTaskBlocked),
+		errors.Is(err, types.ErrWipLimit), errors.Is(err, types.ErrHasSubtasks):
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
@@ -2,11 +2,66 @@ package tasks
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/service/attachments"
+	"github.com/4lerman/pm_service/internal/service/audit"
+	"github.com/4lerman/pm_service/internal/service/fields"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/pkg/rank"
 	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
 )
 
+// BlockedColumn tells whether any task that blocks the task is not done yet
+const BlockedColumn = "EXISTS (SELECT 1 FROM task_dependencies " +
+	"JOIN tasks blocker ON blocker.id = task_dependencies.taskId " +
+	"JOIN workflow_states ON workflow_states.projectId = blocker.projectId AND workflow_states.name = blocker.status " +
+	"WHERE task_dependencies.targetId = tasks.id AND task_dependencies.linkType = 'blocks' " +
+	"AND workflow_states.category <> 'done')"
+
+// LabelsColumn is the sorted names of the labels on a task
+const LabelsColumn = "ARRAY(SELECT labels.name::text FROM task_labels JOIN labels ON labels.id = task_labels.labelId " +
+	"WHERE task_labels.taskId = tasks.id ORDER BY labels.name)"
+
+// TimeSpentColumn is the total of the minutes logged on a task
+const TimeSpentColumn = "(SELECT COALESCE(SUM(durationMinutes), 0) FROM worklogs WHERE worklogs.taskId = tasks.id)"
+
+// Columns is the select list ScanRowIntoTask expects
+const Columns = "id, title, descript, taskType, status, " + workflows.CategoryColumn + ", " + BlockedColumn + ", " +
+	"userId, projectId, parentId, sprintId, milestoneId, rank, " + LabelsColumn + ", " + fields.TaskColumn + ", startDate, dueDate, completedAt, " +
+	"originalEstimate, remainingEstimate, " + TimeSpentColumn + ", createdAt, updatedAt"
+
+// Key of the advisory lock that serialises changes to the task hierarchy
+const hierarchyLock = 0x7461736b
+
+// Class of the per project advisory locks that serialise board changes
+const boardLock = 0x626f6172
+
+// Keyset lists the fields tasks can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":         "id",
+	"title":      "title",
+	"task_type":  "taskType",
+	"status":     "status",
+	"user_id":    "userId",
+	"project_id": "projectId",
+	"rank":       "rank",
+	"created_at": "createdAt",
+	"updated_at": "updatedAt",
+}
+
+// Selects the first state of the workflow of the project bound to the placeholder
+func initialState(projectId string) string {
+	return "SELECT name FROM workflow_states WHERE projectId = " + projectId + " ORDER BY position LIMIT 1"
+}
+
 type Store struct {
 	db *sql.DB
 }
@@ -17,42 +72,61 @@ func NewStore(db *sql.DB) *Store {
 	}
 }
 
-func (s *Store) ListTasks() ([]types.Task, error) {
-	rows, err := s.db.Query("SELECT * FROM tasks")
+func (s *Store) ListTasks(params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{Select: Columns, From: "tasks"}, Keyset, params, ScanRowIntoTask)
+}
 
+func (s *Store) CreateTask(task types.Task, actorId int) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	defer tx.Rollback()
 
-	tasks_list := []types.Task{}
-	for rows.Next() {
-		task, err := ScanRowIntoTask(rows)
-		if err != nil {
-			return nil, err
-		}
+	if err := lockBoard(tx, task.ProjectId); err != nil {
+		return err
+	}
 
-		tasks_list = append(tasks_list, *task)
+	taskRank, err := appendRank(tx, task.ProjectId)
+	if err != nil {
+		return err
 	}
 
-	return tasks_list, nil
-}
+	taskId := 0
+	err = tx.QueryRow("INSERT INTO tasks (title, descript, taskType, status, userId, projectId, parentId, "+
+		"startDate, dueDate, originalEstimate, remainingEstimate, milestoneId, rank)"+
+		"VALUES ($1, $2, $3, ("+initialState("$5")+"), $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
+		task.Title, task.Descript, task.TaskType, task.UserId, task.ProjectId, task.ParentId,
+		task.StartDate, task.DueDate, task.OriginalEstimate, task.RemainingEstimate, task.MilestoneId, taskRank).Scan(&taskId)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkHierarchy(tx, taskId); err != nil {
+		return err
+	}
 
-func (s *Store) CreateTask(task types.Task) error {
-	_, err := s.db.Exec("INSERT INTO tasks (title, descript, taskType, taskPriority, userId, projectId)"+
-		"VALUES ($1, $2, $3, $4, $5, $6)",
-		task.Title, task.Descript, task.TaskType, task.TaskPriority, task.UserId, task.ProjectId)
+	// The new task takes up a place in the first column of the board
+	if err := checkWipLimit(tx, taskId); err != nil {
+		return err
+	}
 
+	created, err := stampCompletion(tx, taskId)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := record(tx, nil, created, types.AuditCreate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *Store) GetTaskById(taskId int) (*types.Task, error) {
-	rows, err := s.db.Query("SELECT * FROM tasks WHERE id = $1", taskId)
+	rows, err := s.db.Query("SELECT "+Columns+" FROM tasks WHERE id = $1", taskId)
 
 	if err != nil {
 		return nil, err
@@ -75,79 +149,724 @@ func (s *Store) GetTaskById(taskId int) (*types.Task, error) {
 	return task, nil
 }
 
-func (s *Store) GetTasksByQuery(queryType string, query string) ([]types.Task, error) {
-	var sqlQuery string
-
-	switch queryType {
-	case "title":
-		sqlQuery = "SELECT * FROM tasks WHERE title ILIKE $1"
-		query = "%"+query+"%"
-	case "status":
-		sqlQuery = "SELECT * FROM tasks WHERE taskPriority = $1"
-	case "priority":
-		sqlQuery = "SELECT * FROM tasks WHERE taskType = $1"
-	case "assignee":
-		sqlQuery = "SELECT * FROM tasks WHERE userId = $1"
-	case "project":
-		sqlQuery = "SELECT * FROM tasks WHERE projectId = $1"
-	default:
-		return nil, fmt.Errorf("invalid query type: %s", queryType)
+// Searches tasks. Custom fields are project specific, so filtering or sorting
+// by them, e.g. sort=custom_fields.story_points, needs a single project filter.
+func (s *Store) SearchTasks(filter types.TaskFilter, params types.ListParams) (*types.Page[types.Task], error) {
+	keyset := Keyset
+	projectFields := []types.CustomField{}
+	nullable := []string{}
+
+	if len(filter.CustomFields) > 0 || sortsByCustomField(params.Sort) {
+		if len(filter.Project.Values) != 1 || filter.Project.Negate {
+			return nil, fmt.Errorf("%w: filtering or sorting by custom fields needs a single project", types.ErrInvalidListParams)
+		}
+
+		var err error
+		if projectFields, err = fields.ListProjectFields(s.db, filter.Project.Values[0]); err != nil {
+			return nil, err
+		}
+
+		keyset = maps.Clone(Keyset)
+		for _, field := range projectFields {
+			keyset[customFieldPrefix+field.Name] = fields.ValueExpression(field)
+			nullable = append(nullable, customFieldPrefix+field.Name)
+		}
 	}
 
-	rows, err := s.db.Query(sqlQuery, query)
+	where, args, err := buildTaskFilter(filter, projectFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Paginate(s.db, db.PageQuery{
+		Select:   Columns,
+		From:     "tasks",
+		Where:    where,
+		Args:     args,
+		Nullable: nullable,
+	}, keyset, params, ScanRowIntoTask)
+}
+
+// Sort keys of custom fields are their path in the task JSON
+const customFieldPrefix = "custom_fields."
+
+func sortsByCustomField(sort []types.SortKey) bool {
+	return slices.ContainsFunc(sort, func(key types.SortKey) bool {
+		return strings.HasPrefix(key.Field, customFieldPrefix)
+	})
+}
+
+func (s *Store) QueryTasks(query types.TaskQuery, params types.ListParams) (*types.Page[types.Task], error) {
+	if len(params.Sort) == 0 {
+		params.Sort = query.Sort
+	}
+
+	return db.Paginate(s.db, db.PageQuery{
+		Select: Columns,
+		From:   "tasks",
+		Where:  query.Where,
+		Args:   query.Args,
+	}, Keyset, params, ScanRowIntoTask)
+}
+
+func (s *Store) UpdateTask(taskId int, task types.Task, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	before, err := queryTask(tx, "SELECT "+Columns+" FROM tasks WHERE id = $1 FOR UPDATE", taskId)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	// A task moved to another project goes to the bottom of its board. Its
+	// subtasks would be left behind in the old project, so it must have none.
+	moved := task.ProjectId != before.ProjectId
+
+	var projectRank *string
+	if moved {
+		var subtasks int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE parentId = $1", taskId).Scan(&subtasks); err != nil {
+			return err
+		}
+
+		if subtasks > 0 {
+			return fmt.Errorf("%w: move or detach its %d subtasks before moving task %d to another project",
+				types.ErrHasSubtasks, subtasks, taskId)
+		}
+
+		if err := lockBoard(tx, task.ProjectId); err != nil {
+			return err
+		}
+
+		taskRank, err := appendRank(tx, task.ProjectId)
+		if err != nil {
+			return err
+		}
+
+		projectRank = &taskRank
+	}
+
+	// A task moved to another project keeps its status if that project's
+	// workflow has a state of the same name and starts over otherwise. It
+	// leaves its sprint, which belongs to the old project.
+	_, err = tx.Exec("UPDATE tasks SET "+
+		"title = $1, descript = $2, taskType = $3, userId = $4, projectId = $5, parentId = $6, "+
+		"startDate = $7, dueDate = $8, originalEstimate = $9, remainingEstimate = $10, milestoneId = $11, updatedAt = NOW(), "+
+		"sprintId = CASE WHEN tasks.projectId = $5 THEN tasks.sprintId END, "+
+		"rank = CASE WHEN tasks.projectId = $5 THEN tasks.rank ELSE $13 END, "+
+		"status = CASE WHEN EXISTS (SELECT 1 FROM workflow_states WHERE projectId = $5 AND name = tasks.status) "+
+		"THEN tasks.status ELSE ("+initialState("$5")+") END "+
+		"WHERE id = $12",
+		task.Title, task.Descript, task.TaskType, task.UserId, task.ProjectId, task.ParentId,
+		task.StartDate, task.DueDate, task.OriginalEstimate, task.RemainingEstimate, task.MilestoneId, taskId, projectRank)
+
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	if err := checkHierarchy(tx, taskId); err != nil {
+		return err
+	}
+
+	// Labels and custom fields belong to the old project, and the task takes
+	// up a place in its column on the new board
+	if moved {
+		if err := dropForeignValues(tx, taskId); err != nil {
+			return err
+		}
+
+		if err := checkWipLimit(tx, taskId); err != nil {
+			return err
+		}
+	}
+
+	after, err := stampCompletion(tx, taskId)
+	if err != nil {
+		return err
+	}
+
+	if err := record(tx, before, after, types.AuditUpdate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Sets the values of a task's custom fields, see fields.WriteValues
+func (s *Store) SetFieldValues(taskId int, values map[int]any, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = Track(tx, taskId, actorId, func(*types.Task) error {
+		return fields.WriteValues(tx, taskId, values)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Moves the task from one state to another. The update only applies while the
+// task is still in the from state and the project's workflow allows the move,
+// so concurrent transitions cannot skip a step.
+func (s *Store) TransitionTask(taskId int, from string, to string, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := transition(tx, taskId, from, to, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Places the task on its project's board, moving it to another column first
+// if the move asks for one. Moving to another column is a transition and
+// follows the same rules. Without neighbours the task goes to the bottom of
+// the column.
+func (s *Store) MoveTask(taskId int, move types.TaskMove, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	task, err := queryTask(tx, "SELECT "+Columns+" FROM tasks WHERE id = $1 FOR NO KEY UPDATE", taskId)
+	if err != nil {
+		return fmt.Errorf("failed to move task: %w", err)
+	}
+
+	if err := lockBoard(tx, task.ProjectId); err != nil {
+		return err
+	}
+
+	if move.Status == "" {
+		move.Status = task.Status
+	}
+
+	if move.Status != task.Status {
+		if err := transition(tx, taskId, task.Status, move.Status, actorId); err != nil {
+			return err
+		}
+	}
+
+	taskRank, err := placeRank(tx, task, move)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE tasks SET rank = $1 WHERE id = $2", taskRank, taskId); err != nil {
+		return fmt.Errorf("failed to move task: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a task and returns the storage keys of its attachments, whose
+// content the caller removes
+func (s *Store) DeleteTask(taskId int, actorId int) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	keys, err := attachments.StorageKeys(tx, "id = $1", taskId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	before, err := queryTask(tx, "DELETE FROM tasks WHERE id = $1 RETURNING "+Columns, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	if err := record(tx, before, nil, types.AuditDelete, actorId); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Returns the open tasks whose due date has passed
+func (s *Store) ListOverdueTasks(params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: Columns,
+		From:   "tasks",
+		Where:  "dueDate < CURRENT_DATE AND " + workflows.CategoryColumn + " <> $1",
+		Args:   []any{types.Done},
+	}, Keyset, params, ScanRowIntoTask)
+}
+
+// Returns the direct subtasks of a task
+func (s *Store) GetTaskChildren(taskId int, params types.ListParams) (*types.Page[types.Task], error) {
+	return db.Paginate(s.db, db.PageQuery{
+		Select: Columns,
+		From:   "tasks",
+		Where:  "parentId = $1",
+		Args:   []any{taskId},
+	}, Keyset, params, ScanRowIntoTask)
+}
+
+// Returns the task with all of its descendants, each with its completion rolled up
+func (s *Store) GetTaskTree(taskId int) (*types.TaskTree, error) {
+	rows, err := s.db.Query("WITH RECURSIVE tree (id) AS ("+
+		"SELECT id FROM tasks WHERE id = $1 "+
+		"UNION SELECT tasks.id FROM tasks JOIN tree ON tasks.parentId = tree.id"+
+		") SELECT "+Columns+" FROM tasks WHERE id IN (SELECT id FROM tree) ORDER BY id", taskId)
+
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	tasks_list := []types.Task{}
+	tasks := []*types.Task{}
 	for rows.Next() {
 		task, err := ScanRowIntoTask(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		tasks_list = append(tasks_list, *task)
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("task not found")
 	}
 
-	return tasks_list, nil
+	return buildTree(tasks, taskId), nil
 }
 
-func (s *Store) UpdateTask(taskId int, task types.Task) error {
-	_, err := s.db.Exec("UPDATE tasks SET "+
-		"title = $1, descript = $2, taskType = $3, taskPriority = $4, userId = $5, projectId = $6, updatedAt = NOW() "+
-		"WHERE id = $7", 
-		task.Title, task.Descript, task.TaskType, task.TaskPriority, task.UserId, task.ProjectId, taskId)
+// Enforces the hierarchy rules on a task that was just written: it may not
+// be its own ancestor, it may not be open under a done parent, and it may not
+// be done while any of its subtasks are open. Checks run under a lock, so
+// that concurrent writes cannot break the rules between them.
+func checkHierarchy(tx *sql.Tx, taskId int) error {
+	var parentId sql.NullInt64
+	var category types.StatusCategory
 
+	err := tx.QueryRow("SELECT parentId, "+workflows.CategoryColumn+" FROM tasks WHERE id = $1", taskId).Scan(&parentId, &category)
 	if err != nil {
-		return fmt.Errorf("failed to update task: %w", err)
+		return err
+	}
+
+	if !parentId.Valid && category != types.Done {
+		return nil
+	}
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", hierarchyLock); err != nil {
+		return err
+	}
+
+	if parentId.Valid {
+		var cycle bool
+		err := tx.QueryRow("WITH RECURSIVE ancestors (id, parentId) AS ("+
+			"SELECT id, parentId FROM tasks WHERE id = $1 "+
+			"UNION SELECT tasks.id, tasks.parentId FROM tasks JOIN ancestors ON tasks.id = ancestors.parentId"+
+			") SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)", parentId.Int64, taskId).Scan(&cycle)
+
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return fmt.Errorf("%w: task %d cannot be its own ancestor", types.ErrInvalidParent, taskId)
+		}
+
+		var parentCategory types.StatusCategory
+		err = tx.QueryRow("SELECT "+workflows.CategoryColumn+" FROM tasks WHERE id = $1", parentId.Int64).Scan(&parentCategory)
+		if err != nil {
+			return err
+		}
+
+		if parentCategory == types.Done && category != types.Done {
+			return fmt.Errorf("%w: reopen task %d first", types.ErrParentDone, parentId.Int64)
+		}
+	}
+
+	if category == types.Done {
+		var open int
+		err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE parentId = $1 AND "+workflows.CategoryColumn+" <> $2",
+			taskId, types.Done).Scan(&open)
+
+		if err != nil {
+			return err
+		}
+
+		if open > 0 {
+			return fmt.Errorf("%w: %d subtasks are not done", types.ErrOpenSubtasks, open)
+		}
 	}
 
 	return nil
 }
 
-func (s *Store) DeleteTask(taskId int) error {
-	_, err := s.db.Exec("DELETE FROM tasks WHERE id = $1", taskId)
+// Changes the status of a task within a transaction, see TransitionTask
+func transition(tx *sql.Tx, taskId int, from string, to string, actorId int) error {
+	before, err := queryTask(tx, "SELECT "+Columns+" FROM tasks WHERE id = $1 FOR UPDATE", taskId)
+	if err != nil {
+		return fmt.Errorf("failed to transition task: %w", err)
+	}
+
+	res, err := tx.Exec("UPDATE tasks SET status = $1, updatedAt = NOW() "+
+		"WHERE id = $2 AND status = $3 AND EXISTS ("+
+		"SELECT 1 FROM workflow_transitions WHERE projectId = tasks.projectId AND fromState = $3 AND toState = $1)",
+		to, taskId, from)
+
+	if err != nil {
+		return fmt.Errorf("failed to transition task: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: from %q to %q", types.ErrIllegalTransition, from, to)
+	}
+
+	if err := checkWipLimit(tx, taskId); err != nil {
+		return err
+	}
+
+	if err := checkHierarchy(tx, taskId); err != nil {
+		return err
+	}
+
+	if err := checkBlocked(tx, taskId); err != nil {
+		return err
+	}
+
+	after, err := stampCompletion(tx, taskId)
+	if err != nil {
+		return err
+	}
+
+	return record(tx, before, after, types.AuditUpdate, actorId)
+}
+
+// Removes the labels and custom field values of the task that belong to
+// another project than its own
+func dropForeignValues(tx *sql.Tx, taskId int) error {
+	_, err := tx.Exec("DELETE FROM task_labels USING labels, tasks "+
+		"WHERE task_labels.taskId = $1 AND labels.id = task_labels.labelId AND tasks.id = task_labels.taskId "+
+		"AND labels.projectId <> tasks.projectId", taskId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM task_field_values USING custom_fields, tasks "+
+		"WHERE task_field_values.taskId = $1 AND custom_fields.id = task_field_values.fieldId AND tasks.id = task_field_values.taskId "+
+		"AND custom_fields.projectId <> tasks.projectId", taskId)
+
+	return err
+}
+
+// A task cannot be created in or move into a column that already holds as
+// many tasks as its WIP limit allows. Runs after the task has been written,
+// under the board lock so that concurrent writes to the same column are
+// counted one after another.
+func checkWipLimit(tx *sql.Tx, taskId int) error {
+	var projectId int
+	var status string
+	var limit sql.NullInt64
 
+	err := tx.QueryRow("SELECT tasks.projectId, tasks.status, workflow_states.wipLimit FROM tasks "+
+		"JOIN workflow_states ON workflow_states.projectId = tasks.projectId AND workflow_states.name = tasks.status "+
+		"WHERE tasks.id = $1", taskId).Scan(&projectId, &status, &limit)
+
+	if err != nil || !limit.Valid {
+		return err
+	}
+
+	if err := lockBoard(tx, projectId); err != nil {
+		return err
+	}
+
+	var count int64
+	err = tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE projectId = $1 AND status = $2", projectId, status).Scan(&count)
 	if err != nil {
-		return fmt.Errorf("failed to delete task: %w", err)
+		return err
+	}
+
+	if count > limit.Int64 {
+		return fmt.Errorf("%w: %q holds at most %d tasks", types.ErrWipLimit, status, limit.Int64)
+	}
+
+	return nil
+}
+
+// Takes the lock that serialises changes to the ranks and columns of a
+// project's board until the transaction ends
+func lockBoard(tx *sql.Tx, projectId int) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", boardLock, projectId); err != nil {
+		return fmt.Errorf("failed to lock board: %w", err)
 	}
 
 	return nil
 }
 
+// Returns the rank that puts a task at the bottom of its project's board
+func appendRank(tx *sql.Tx, projectId int) (string, error) {
+	var last string
+	if err := tx.QueryRow("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE projectId = $1", projectId).Scan(&last); err != nil {
+		return "", err
+	}
+
+	return rank.After(last), nil
+}
+
+// Works out the rank that puts the task where the move asks for. Ranks are
+// unique within a project, so the new rank is taken between two ranks that
+// are adjacent across the whole project, not just the column.
+func placeRank(tx *sql.Tx, task *types.Task, move types.TaskMove) (string, error) {
+	neighbour := func(neighbourId int) (string, error) {
+		var neighbourRank string
+		err := tx.QueryRow("SELECT rank FROM tasks WHERE id = $1 AND id <> $2 AND projectId = $3 AND status = $4",
+			neighbourId, task.ID, task.ProjectId, move.Status).Scan(&neighbourRank)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w: task %d is not in column %q", types.ErrInvalidMove, neighbourId, move.Status)
+		}
+
+		return neighbourRank, err
+	}
+
+	adjacent := func(query string, args ...any) (string, error) {
+		var adjacentRank string
+		err := tx.QueryRow(query, append([]any{task.ProjectId, task.ID}, args...)...).Scan(&adjacentRank)
+		return adjacentRank, err
+	}
+
+	var lower, upper string
+	var err error
+
+	switch {
+	case move.AfterId != nil:
+		if lower, err = neighbour(*move.AfterId); err != nil {
+			return "", err
+		}
+
+		if move.BeforeId != nil {
+			before, err := neighbour(*move.BeforeId)
+			if err != nil {
+				return "", err
+			}
+
+			// The neighbours must be next to each other in the column
+			var between bool
+			err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE projectId = $1 AND id <> $2 AND status = $3 "+
+				"AND rank > $4 AND rank < $5)", task.ProjectId, task.ID, move.Status, lower, before).Scan(&between)
+
+			if err != nil {
+				return "", err
+			}
+
+			if before <= lower || between {
+				return "", fmt.Errorf("%w: task %d is not right above task %d", types.ErrInvalidMove, *move.AfterId, *move.BeforeId)
+			}
+		}
+
+		upper, err = adjacent("SELECT COALESCE(MIN(rank), '') FROM tasks WHERE projectId = $1 AND id <> $2 AND rank > $3", lower)
+
+	case move.BeforeId != nil:
+		if upper, err = neighbour(*move.BeforeId); err != nil {
+			return "", err
+		}
+
+		lower, err = adjacent("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE projectId = $1 AND id <> $2 AND rank < $3", upper)
+
+	default:
+		lower, err = adjacent("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE projectId = $1 AND id <> $2")
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return rank.Between(lower, upper)
+}
+
+// Sets completedAt when the task reaches a done state and clears it when the
+// task is reopened. Returns the task as it is afterwards.
+func stampCompletion(tx *sql.Tx, taskId int) (*types.Task, error) {
+	return queryTask(tx, "UPDATE tasks SET completedAt = CASE WHEN "+workflows.CategoryColumn+" = $1 "+
+		"THEN COALESCE(completedAt, NOW()) END WHERE id = $2 RETURNING "+Columns, types.Done, taskId)
+}
+
+// A blocked task cannot be done until the tasks blocking it are
+func checkBlocked(tx *sql.Tx, taskId int) error {
+	var category types.StatusCategory
+	var blocked bool
+
+	err := tx.QueryRow("SELECT "+workflows.CategoryColumn+", "+BlockedColumn+" FROM tasks WHERE id = $1", taskId).Scan(&category, &blocked)
+	if err != nil {
+		return err
+	}
+
+	if blocked && category == types.Done {
+		return fmt.Errorf("%w: finish the tasks blocking it first", types.ErrTaskBlocked)
+	}
+
+	return nil
+}
+
+// Nests the tasks under their parents and computes the completion of every
+// node: a leaf is 0 or 100 depending on whether it is done, a parent is the
+// average of its children
+func buildTree(tasks []*types.Task, rootId int) *types.TaskTree {
+	children := map[int][]*types.Task{}
+	var root *types.Task
+
+	for _, task := range tasks {
+		if task.ID == rootId {
+			root = task
+		} else if task.ParentId != nil {
+			children[*task.ParentId] = append(children[*task.ParentId], task)
+		}
+	}
+
+	var build func(task *types.Task) types.TaskTree
+	build = func(task *types.Task) types.TaskTree {
+		node := types.TaskTree{Task: *task, Children: []types.TaskTree{}}
+
+		total := 0
+		for _, child := range children[task.ID] {
+			subtree := build(child)
+			total += subtree.Progress
+			node.Children = append(node.Children, subtree)
+		}
+
+		switch {
+		case len(node.Children) > 0:
+			node.Progress = total / len(node.Children)
+		case task.Category == types.Done:
+			node.Progress = 100
+		}
+
+		return node
+	}
+
+	tree := build(root)
+	return &tree
+}
+
+// Runs a statement that returns a single task row, such as an INSERT or UPDATE with RETURNING
+func queryTask(tx *sql.Tx, query string, args ...any) (*types.Task, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("task not found")
+	}
+
+	return ScanRowIntoTask(rows)
+}
+
+// Track runs a change that another store makes to a task, such as logging
+// work on it, within that store's transaction. The task is locked and passed
+// to change as it was before; if the change shows in the task, its updatedAt
+// is bumped and the change is recorded in the audit trail.
+func Track(tx *sql.Tx, taskId int, actorId int, change func(before *types.Task) error) error {
+	before, err := queryTask(tx, "SELECT "+Columns+" FROM tasks WHERE id = $1 FOR UPDATE", taskId)
+	if err != nil {
+		return err
+	}
+
+	if err := change(before); err != nil {
+		return err
+	}
+
+	after, err := queryTask(tx, "SELECT "+Columns+" FROM tasks WHERE id = $1", taskId)
+	if err != nil {
+		return err
+	}
+
+	changes, err := audit.Diff(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE tasks SET updatedAt = NOW() WHERE id = $1", taskId); err != nil {
+		return err
+	}
+
+	return audit.Record(tx, types.AuditTask, taskId, types.AuditUpdate, actorId, changes)
+}
+
+// Records the change between two versions of a task in the audit trail
+func record(tx *sql.Tx, before *types.Task, after *types.Task, action types.AuditAction, actorId int) error {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	task := after
+	if task == nil {
+		task = before
+	}
+
+	return audit.Record(tx, types.AuditTask, task.ID, action, actorId, changes)
+}
+
 func ScanRowIntoTask(rows *sql.Rows) (*types.Task, error) {
 	task := new(types.Task)
 
+	var parentId, sprintId, milestoneId sql.NullInt64
+	var customFields []byte
+
 	err := rows.Scan(
 		&task.ID,
 		&task.Title,
 		&task.Descript,
 		&task.TaskType,
-		&task.TaskPriority,
+		&task.Status,
+		&task.Category,
+		&task.Blocked,
 		&task.UserId,
 		&task.ProjectId,
+		&parentId,
+		&sprintId,
+		&milestoneId,
+		&task.Rank,
+		pq.Array(&task.Labels),
+		&customFields,
+		&task.StartDate,
+		&task.DueDate,
+		&task.CompletedAt,
+		&task.OriginalEstimate,
+		&task.RemainingEstimate,
+		&task.TimeSpent,
 		&task.CreatedAt,
 		&task.UpdatedAt,
 	)
@@ -156,5 +875,24 @@ func ScanRowIntoTask(rows *sql.Rows) (*types.Task, error) {
 		return nil, err
 	}
 
+	if parentId.Valid {
+		id := int(parentId.Int64)
+		task.ParentId = &id
+	}
+
+	if sprintId.Valid {
+		id := int(sprintId.Int64)
+		task.SprintId = &id
+	}
+
+	if milestoneId.Valid {
+		id := int(milestoneId.Int64)
+		task.MilestoneId = &id
+	}
+
+	if err := json.Unmarshal(customFields, &task.CustomFields); err != nil {
+		return nil, err
+	}
+
 	return task, nil
 }
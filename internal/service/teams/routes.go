@@ -0,0 +1,382 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store     types.TeamStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.TeamStore, userStore types.UserStore) *Handler {
+	return &Handler{
+		store:     store,
+		userStore: userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.handleListTeams).Methods(http.MethodGet)
+	router.HandleFunc("", h.handleCreateTeam).Methods(http.MethodPost)
+	router.HandleFunc("/{id}", h.handleGetTeamById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", h.handleUpdateTeam).Methods(http.MethodPut)
+	router.HandleFunc("/{id}", h.handleDeleteTeam).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/members", h.handleListTeamMembers).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/members", h.handleAddTeamMember).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/members/{userId}", h.handleRemoveTeamMember).Methods(http.MethodDelete)
+}
+
+// @Summary List all teams
+// @Description Get a list of all teams with their lead and number of members
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,name"
+// @Success 200 {object} types.Page[types.Team]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams [get]
+func (h *Handler) handleListTeams(w http.ResponseWriter, r *http.Request) {
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	teams, err := h.store.ListTeams(params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, teams)
+}
+
+// @Summary Create a team
+// @Description Create a team of users. The lead becomes a member of the team.
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param team body types.TeamPayload true "Team details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams [post]
+func (h *Handler) handleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.CreateTeam, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := h.parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.CreateTeam(types.Team{
+		Name:     payload.Name,
+		Descript: payload.Descript,
+		LeadId:   payload.LeadId,
+	})
+
+	if err != nil {
+		utils.WriteError(w, teamErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get team by ID
+// @Description Get a single team with its lead and number of members
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Success 200 {object} types.Team
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id} [get]
+func (h *Handler) handleGetTeamById(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, team)
+}
+
+// @Summary Update a team
+// @Description Change the name, description or lead of a team. A new lead becomes a member of the team.
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Param team body types.TeamPayload true "Team details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id} [put]
+func (h *Handler) handleUpdateTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := authorize(r, policy.ManageTeam, team); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := h.parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateTeam(team.ID, types.Team{
+		Name:     payload.Name,
+		Descript: payload.Descript,
+		LeadId:   payload.LeadId,
+	})
+
+	if err != nil {
+		utils.WriteError(w, teamErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a team
+// @Description Delete a team. The projects it owns are kept without an owning team.
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id} [delete]
+func (h *Handler) handleDeleteTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := authorize(r, policy.ManageTeam, team); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteTeam(team.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary List team members
+// @Description Get the members of a team, including its lead
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Success 200 {array} types.TeamMember
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id}/members [get]
+func (h *Handler) handleListTeamMembers(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.store.ListTeamMembers(team.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, members)
+}
+
+// @Summary Add team member
+// @Description Add a user to a team
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Param member body types.AddTeamMemberPayload true "Member details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id}/members [post]
+func (h *Handler) handleAddTeamMember(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := authorize(r, policy.ManageTeam, team); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.AddTeamMemberPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	if _, err := h.userStore.GetUserById(payload.UserId); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user_id: %v", err))
+		return
+	}
+
+	err := h.store.AddTeamMember(types.TeamMember{
+		TeamId: team.ID,
+		UserId: payload.UserId,
+	})
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Member added successfully"})
+}
+
+// @Summary Remove team member
+// @Description Remove a user from a team. The team lead cannot be removed.
+// @Tags Teams
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Team ID"
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /teams/{id}/members/{userId} [delete]
+func (h *Handler) handleRemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	team, ok := h.getTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := authorize(r, policy.ManageTeam, team); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	userId, _ := strconv.Atoi(mux.Vars(r)["userId"])
+
+	if err := h.store.RemoveTeamMember(team.ID, userId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Member removed successfully"})
+}
+
+func (h *Handler) getTeam(w http.ResponseWriter, r *http.Request) (*types.Team, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	teamId, _ := strconv.Atoi(id)
+
+	team, err := h.store.GetTeamById(teamId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get team by id: %v", err))
+		return nil, false
+	}
+
+	return team, true
+}
+
+// The lead of a team manages it together with the admins
+func authorize(r *http.Request, action policy.Action, team *types.Team) error {
+	resource := policy.Resource{}
+	if team.LeadId != nil {
+		resource.OwnerId = *team.LeadId
+	}
+
+	return policy.Authorize(auth.GetUserFromContext(r.Context()), action, resource)
+}
+
+func (h *Handler) parsePayload(w http.ResponseWriter, r *http.Request) (*types.TeamPayload, bool) {
+	var payload types.TeamPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	if payload.LeadId != nil {
+		if _, err := h.userStore.GetUserById(*payload.LeadId); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid lead_id: %v", err))
+			return nil, false
+		}
+	}
+
+	return &payload, true
+}
+
+func teamErrorStatus(err error) int {
+	if errors.Is(err, types.ErrTeamExists) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
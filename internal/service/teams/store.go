@@ -0,0 +1,235 @@
+package teams
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/4lerman/pm_service/pkg/db"
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Columns is the select list ScanRowIntoTeam expects
+const Columns = "id, name, descript, leadId, " +
+	"(SELECT COUNT(*) FROM team_members WHERE team_members.teamId = teams.id), createdAt, updatedAt"
+
+// Keyset lists the fields teams can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "createdAt",
+	"updated_at": "updatedAt",
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListTeams(params types.ListParams) (*types.Page[types.Team], error) {
+	return db.Paginate(s.db, db.PageQuery{Select: Columns, From: "teams"}, Keyset, params, ScanRowIntoTeam)
+}
+
+func (s *Store) GetTeamById(teamId int) (*types.Team, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM teams WHERE id = $1", teamId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	team := new(types.Team)
+	for rows.Next() {
+		team, err = ScanRowIntoTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if team.ID == 0 {
+		return nil, fmt.Errorf("team not found")
+	}
+
+	return team, nil
+}
+
+// Creates a team, making its lead a member
+func (s *Store) CreateTeam(team types.Team) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	teamId := 0
+	err = tx.QueryRow("INSERT INTO teams (name, descript, leadId) VALUES ($1, $2, $3) RETURNING id",
+		team.Name, team.Descript, team.LeadId).Scan(&teamId)
+
+	if err != nil {
+		return fmt.Errorf("failed to create team: %w", uniqueViolation(err))
+	}
+
+	if err := addLead(tx, teamId, team.LeadId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Changes the details of a team, making a new lead a member
+func (s *Store) UpdateTeam(teamId int, team types.Team) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE teams SET name = $1, descript = $2, leadId = $3, updatedAt = NOW() WHERE id = $4",
+		team.Name, team.Descript, team.LeadId, teamId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update team: %w", uniqueViolation(err))
+	}
+
+	if err := addLead(tx, teamId, team.LeadId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a team; the projects it owns are kept without a team
+func (s *Store) DeleteTeam(teamId int) error {
+	_, err := s.db.Exec("DELETE FROM teams WHERE id = $1", teamId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete team: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) ListTeamMembers(teamId int) ([]types.TeamMember, error) {
+	rows, err := s.db.Query("SELECT teamId, userId, addedAt FROM team_members WHERE teamId = $1 ORDER BY addedAt", teamId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []types.TeamMember{}
+	for rows.Next() {
+		member, err := ScanRowIntoTeamMember(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, *member)
+	}
+
+	return members, nil
+}
+
+// Adds a user to a team; adding a member again changes nothing
+func (s *Store) AddTeamMember(member types.TeamMember) error {
+	_, err := s.db.Exec("INSERT INTO team_members (teamId, userId) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		member.TeamId, member.UserId)
+
+	if err != nil {
+		return fmt.Errorf("failed to add team member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveTeamMember(teamId int, userId int) error {
+	res, err := s.db.Exec("DELETE FROM team_members WHERE teamId = $1 AND userId = $2 "+
+		"AND userId IS DISTINCT FROM (SELECT leadId FROM teams WHERE id = $1)", teamId, userId)
+
+	if err != nil {
+		return fmt.Errorf("failed to remove team member: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to remove team member: not a member or the team lead")
+	}
+
+	return nil
+}
+
+func addLead(tx *sql.Tx, teamId int, leadId *int) error {
+	if leadId == nil {
+		return nil
+	}
+
+	_, err := tx.Exec("INSERT INTO team_members (teamId, userId) VALUES ($1, $2) ON CONFLICT DO NOTHING", teamId, *leadId)
+
+	if err != nil {
+		return fmt.Errorf("failed to add team lead: %w", err)
+	}
+
+	return nil
+}
+
+// Team names are unique
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.ErrTeamExists
+	}
+
+	return err
+}
+
+func ScanRowIntoTeamMember(rows *sql.Rows) (*types.TeamMember, error) {
+	member := new(types.TeamMember)
+
+	err := rows.Scan(
+		&member.TeamId,
+		&member.UserId,
+		&member.AddedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func ScanRowIntoTeam(rows *sql.Rows) (*types.Team, error) {
+	team := new(types.Team)
+
+	var leadId sql.NullInt64
+
+	err := rows.Scan(
+		&team.ID,
+		&team.Name,
+		&team.Descript,
+		&leadId,
+		&team.MemberCount,
+		&team.CreatedAt,
+		&team.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if leadId.Valid {
+		id := int(leadId.Int64)
+		team.LeadId = &id
+	}
+
+	return team, nil
+}
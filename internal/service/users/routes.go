@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
 	"github.com/4lerman/pm_service/types"
 	"github.com/4lerman/pm_service/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
+// Longest range a workload can cover, in days
+const maxRange = 366
+
 type Handler struct {
 	store types.UserStore
 }
@@ -25,10 +31,12 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("", h.handleListUsers).Methods(http.MethodGet)
 	router.HandleFunc("", h.handleCreateUser).Methods(http.MethodPost)
 	router.HandleFunc("/search", h.handleUserByNameOrEmail).Methods(http.MethodGet)
+	router.HandleFunc("/workload", h.handleGetWorkloadMatrix).Methods(http.MethodGet)
 	router.HandleFunc("/{id}", h.handleGetUserById).Methods(http.MethodGet)
 	router.HandleFunc("/{id}", h.handleUpdateUser).Methods(http.MethodPut)
 	router.HandleFunc("/{id}", h.handleDeleteUser).Methods(http.MethodDelete)
 	router.HandleFunc("/{id}/tasks", h.handleGetUserTasks).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/workload", h.handleGetUserWorkload).Methods(http.MethodGet)
 }
 
 // @Summary List all users
@@ -36,14 +44,25 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 // @Tags Users
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} types.User
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.User]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users [get]
 func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.store.ListUsers()
+	params, err := utils.ParseListParams(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := h.store.ListUsers(params)
 
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
 		return
 	}
 
@@ -58,9 +77,16 @@ func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
 // @Param user body types.CreateUserPayload true "User details"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users [post]
 func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ManageUsers, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	var payload types.CreateUserPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
@@ -73,11 +99,17 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.store.CreateUser(types.User{
+	passwordHash, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.store.CreateUser(types.User{
 		FullName: payload.FullName,
 		Email:    payload.Email,
 		UserRole: payload.UserRole,
-	})
+	}, passwordHash, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
@@ -97,6 +129,7 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} types.User
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /users/{id} [get]
 func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -119,7 +152,7 @@ func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Update user details
-// @Description Update user details by ID
+// @Description Update user details by ID. The weekly capacity in hours is kept when omitted.
 // @Tags Users
 // @Accept  json
 // @Produce  json
@@ -127,10 +160,17 @@ func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
 // @Param user body types.UpdateUserPayload true "User details"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users/{id} [put]
 func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ManageUsers, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
@@ -141,6 +181,12 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	userId, _ := strconv.Atoi(id)
 
+	user, err := h.store.GetUserById(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get user by id: %v", err))
+		return
+	}
+
 	var payload types.UpdateUserPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
@@ -153,10 +199,15 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.store.UpdateUser(userId, types.User{
-		FullName: payload.FullName,
-		UserRole: payload.UserRole,
-	})
+	if payload.WeeklyCapacity != nil {
+		user.WeeklyCapacity = *payload.WeeklyCapacity
+	}
+
+	err = h.store.UpdateUser(userId, types.User{
+		FullName:       payload.FullName,
+		UserRole:       payload.UserRole,
+		WeeklyCapacity: user.WeeklyCapacity,
+	}, auth.GetUserIdFromContext(r.Context()))
 
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
@@ -174,10 +225,17 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "User ID"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users/{id} [delete]
 func (h *Handler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ManageUsers, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
@@ -188,7 +246,7 @@ func (h *Handler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	userId, _ := strconv.Atoi(id)
 
-	if err := h.store.DeleteUser(userId); err != nil {
+	if err := h.store.DeleteUser(userId, auth.GetUserIdFromContext(r.Context())); err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -197,15 +255,20 @@ func (h *Handler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get user tasks
-// @Description Get all tasks assigned to a user
+// @Description Get all tasks assigned to a user, optionally only those due before a date
 // @Tags Users
 // @Accept  json
 // @Produce  json
 // @Param id path int true "User ID"
-// @Success 200 {array} types.Task
+// @Param due_before query string false "Only tasks due before this date (YYYY-MM-DD)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending, e.g. -updated_at,title"
+// @Success 200 {object} types.Page[types.Task]
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users/{id}/tasks [get]
 func (h *Handler) handleGetUserTasks(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -222,15 +285,120 @@ func (h *Handler) handleGetUserTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks_list, err := h.store.GetUserTasks(userId)
+	params, err := utils.ParseListParams(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var dueBefore *time.Time
+	if raw := r.URL.Query().Get("due_before"); raw != "" {
+		date, err := types.ParseDate(raw)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		dueBefore = &date.Time
+	}
+
+	tasks_list, err := h.store.GetUserTasks(userId, dueBefore, params)
+	if err != nil {
+		utils.WriteError(w, utils.ListErrorStatus(err), err)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, tasks_list)
 }
 
+// @Summary Get user workload
+// @Description Compare the remaining estimates of a user's open tasks across all projects with their weekly capacity between two days, inclusive.
+// @Description The estimate of a task is spread evenly from its start to its due date; tasks without a due date or estimate are left out.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.Workload
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/workload [get]
+func (h *Handler) handleGetUserWorkload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	userId, _ := strconv.Atoi(id)
+	if _, err := h.store.GetUserById(userId); err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get user by id: %v", err))
+		return
+	}
+
+	from, to, err := utils.ParseDateRange(r, maxRange)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	workload, err := h.store.GetUserWorkload(userId, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, workload)
+}
+
+// @Summary Get workload matrix
+// @Description Get the workload of every user, or of the members of a project or team, week by week between two days, inclusive. Weeks start on Monday.
+// @Description Each cell compares the work planned for the user in that week with their capacity, see the user workload.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Param project query int false "Only the members of this project"
+// @Param team query int false "Only the members of this team"
+// @Success 200 {object} types.WorkloadMatrix
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/workload [get]
+func (h *Handler) handleGetWorkloadMatrix(w http.ResponseWriter, r *http.Request) {
+	from, to, err := utils.ParseDateRange(r, maxRange)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	projectId, err := parseOptionalId(r, "project")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	teamId, err := parseOptionalId(r, "team")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	matrix, err := h.store.GetWorkloadMatrix(projectId, teamId, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, matrix)
+}
+
 // @Summary Search users by name or email
 // @Description Search users by name or email
 // @Tags Users
@@ -241,6 +409,7 @@ func (h *Handler) handleGetUserTasks(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {array} types.User
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
 // @Router /users/search [get]
 func (h *Handler) handleUserByNameOrEmail(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
@@ -265,3 +434,18 @@ func (h *Handler) handleUserByNameOrEmail(w http.ResponseWriter, r *http.Request
 
 	utils.WriteJSON(w, http.StatusOK, users)
 }
+
+// Reads an optional id from the query string, nil when it is absent
+func parseOptionalId(r *http.Request, key string) (*int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", key, value)
+	}
+
+	return &id, nil
+}
@@ -2,12 +2,30 @@ package users
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"time"
 
+	"github.com/4lerman/pm_service/internal/service/audit"
 	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/internal/service/workflows"
+	"github.com/4lerman/pm_service/pkg/db"
 	"github.com/4lerman/pm_service/types"
 )
 
+// Columns is the select list ScanRowIntoUser expects
+const Columns = "id, fullName, email, registerDate, userRole, weeklyCapacity"
+
+// Keyset lists the fields users can be sorted and paginated by
+var Keyset = db.Keyset{
+	"id":            "id",
+	"full_name":     "fullName",
+	"email":         "email",
+	"register_date": "registerDate",
+	"user_role":     "userRole",
+}
+
 type Store struct {
 	db *sql.DB
 }
@@ -18,41 +36,40 @@ func NewStore(db *sql.DB) *Store {
 	}
 }
 
-func (s *Store) ListUsers() ([]types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users")
+func (s *Store) ListUsers(params types.ListParams) (*types.Page[types.User], error) {
+	return db.Paginate(s.db, db.PageQuery{Select: Columns, From: "users"}, Keyset, params, ScanRowIntoUser)
+}
 
+func (s *Store) CreateUser(user types.User, passwordHash string, actorId int) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	defer tx.Rollback()
 
-	users := []types.User{}
-	for rows.Next() {
-		user, err := ScanRowIntoUser(rows)
-		if err != nil {
-			return nil, err
-		}
+	created, err := queryUser(tx, "INSERT INTO users (fullName, email, userRole)"+
+		"VALUES ($1, $2, $3) RETURNING "+Columns, user.FullName, user.Email, user.UserRole)
 
-		users = append(users, *user)
+	if err != nil {
+		return err
 	}
 
-	return users, nil
-}
-
-func (s *Store) CreateUser(user types.User) error {
-	_, err := s.db.Exec("INSERT INTO users (fullName, email, userRole)"+
-		"VALUES ($1, $2, $3)", user.FullName, user.Email, user.UserRole)
+	_, err = tx.Exec("INSERT INTO credentials (userId, passwordHash) VALUES ($1, $2)", created.ID, passwordHash)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := record(tx, nil, created, types.AuditCreate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *Store) GetUserById(userId int) (*types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE id = $1", userId)
+	rows, err := s.db.Query("SELECT "+Columns+" FROM users WHERE id = $1", userId)
 
 	if err != nil {
 		return nil, err
@@ -76,7 +93,7 @@ func (s *Store) GetUserById(userId int) (*types.User, error) {
 }
 
 func (s *Store) GetUsersByEmail(email string) ([]types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE email ILIKE $1", "%"+email+"%")
+	rows, err := s.db.Query("SELECT "+Columns+" FROM users WHERE email ILIKE $1", "%"+email+"%")
 
 	if err != nil {
 		return nil, err
@@ -98,7 +115,7 @@ func (s *Store) GetUsersByEmail(email string) ([]types.User, error) {
 }
 
 func (s *Store) GetUsersByName(name string) ([]types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE fullName ILIKE $1", "%"+name+"%")
+	rows, err := s.db.Query("SELECT "+Columns+" FROM users WHERE fullName ILIKE $1", "%"+name+"%")
 
 	if err != nil {
 		return nil, err
@@ -119,29 +136,144 @@ func (s *Store) GetUsersByName(name string) ([]types.User, error) {
 	return users, nil
 }
 
-func (s *Store) UpdateUser(userId int, user types.User) error {
-	_, err := s.db.Exec("UPDATE users SET "+
-		"fullName = $1, userRole = $2 WHERE id = $3", user.FullName, user.UserRole, userId)
+func (s *Store) UpdateUser(userId int, user types.User, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	before, err := queryUser(tx, "SELECT "+Columns+" FROM users WHERE id = $1 FOR UPDATE", userId)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	after, err := queryUser(tx, "UPDATE users SET "+
+		"fullName = $1, userRole = $2, weeklyCapacity = $3 WHERE id = $4 RETURNING "+Columns,
+		user.FullName, user.UserRole, user.WeeklyCapacity, userId)
 
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
-	return nil
+	if err := record(tx, before, after, types.AuditUpdate, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *Store) DeleteUser(userId int) error {
-	_, err := s.db.Exec("DELETE FROM users WHERE id = $1", userId)
+func (s *Store) DeleteUser(userId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
 
+	defer tx.Rollback()
+
+	before, err := queryUser(tx, "DELETE FROM users WHERE id = $1 RETURNING "+Columns, userId)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
-	return nil
+	if err := record(tx, before, nil, types.AuditDelete, actorId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns the tasks assigned to a user, only those due before dueBefore if it is set
+func (s *Store) GetUserTasks(userId int, dueBefore *time.Time, params types.ListParams) (*types.Page[types.Task], error) {
+	where, args := "userId = $1", []any{userId}
+	if dueBefore != nil {
+		where, args = where+" AND dueDate < $2", append(args, *dueBefore)
+	}
+
+	return db.Paginate(s.db, db.PageQuery{
+		Select: tasks.Columns,
+		From:   "tasks",
+		Where:  where,
+		Args:   args,
+	}, tasks.Keyset, params, tasks.ScanRowIntoTask)
+}
+
+// Compares the work planned for a user between two days with their capacity,
+// broken down by project
+func (s *Store) GetUserWorkload(userId int, from types.Date, to types.Date) (*types.Workload, error) {
+	var weeklyCapacity int
+	err := s.db.QueryRow("SELECT weeklyCapacity FROM users WHERE id = $1", userId).Scan(&weeklyCapacity)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT projects.id, projects.title, tasks.startDate, tasks.dueDate, tasks.remainingEstimate "+
+		"FROM tasks JOIN projects ON projects.id = tasks.projectId "+
+		"WHERE tasks.userId = $1 AND "+planned("$2::date", "$3::date")+" "+
+		"ORDER BY projects.title, projects.id", userId, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	workload := &types.Workload{
+		UserId:          userId,
+		From:            from,
+		To:              to,
+		CapacityMinutes: capacity(weeklyCapacity, from, to),
+		Projects:        []types.ProjectWorkload{},
+	}
+
+	var minutes []float64
+	for rows.Next() {
+		var project types.ProjectWorkload
+		var task plannedTask
+
+		if err := rows.Scan(&project.ProjectId, &project.Title, &task.start, &task.due, &task.remaining); err != nil {
+			return nil, err
+		}
+
+		if last := len(workload.Projects) - 1; last < 0 || workload.Projects[last].ProjectId != project.ProjectId {
+			workload.Projects = append(workload.Projects, project)
+			minutes = append(minutes, 0)
+		}
+
+		workload.Projects[len(workload.Projects)-1].Tasks++
+		minutes[len(minutes)-1] += task.allocation(from, to)
+	}
+
+	for i := range workload.Projects {
+		workload.Projects[i].AllocatedMinutes = int(math.Round(minutes[i]))
+		workload.AllocatedMinutes += workload.Projects[i].AllocatedMinutes
+	}
+
+	if workload.CapacityMinutes > 0 {
+		workload.Utilization = workload.AllocatedMinutes * 100 / workload.CapacityMinutes
+	}
+
+	workload.OverAllocated = workload.AllocatedMinutes > workload.CapacityMinutes
+
+	return workload, nil
 }
 
-func (s *Store) GetUserTasks(userId int) ([]types.Task, error) {
-	rows, err := s.db.Query("SELECT * FROM tasks WHERE userId = $1", userId)
+// Lays out the workload of every user, narrowed to the members of a project
+// when projectId is set and of a team when teamId is set, week by week between
+// two days
+func (s *Store) GetWorkloadMatrix(projectId *int, teamId *int, from types.Date, to types.Date) (*types.WorkloadMatrix, error) {
+	rows, err := s.db.Query("SELECT users.id, users.fullName, users.weeklyCapacity, "+
+		"tasks.startDate, tasks.dueDate, tasks.remainingEstimate FROM users "+
+		"LEFT JOIN tasks ON tasks.userId = users.id AND "+planned("$1::date", "$2::date")+" "+
+		"WHERE ($3::int IS NULL OR users.id IN (SELECT userId FROM project_members WHERE projectId = $3)) "+
+		"AND ($4::int IS NULL OR users.id IN (SELECT userId FROM team_members WHERE teamId = $4)) "+
+		"ORDER BY users.fullName, users.id", from, to, projectId, teamId)
 
 	if err != nil {
 		return nil, err
@@ -149,17 +281,102 @@ func (s *Store) GetUserTasks(userId int) ([]types.Task, error) {
 
 	defer rows.Close()
 
-	tasks_list := []types.Task{}
+	matrix := &types.WorkloadMatrix{
+		From:  from,
+		To:    to,
+		Weeks: weeks(from, to),
+		Users: []types.UserWorkload{},
+	}
+
+	var minutes [][]float64
 	for rows.Next() {
-		task, err := tasks.ScanRowIntoTask(rows)
+		var user types.UserWorkload
+		var start, due *types.Date
+		var remaining *int
+
+		err := rows.Scan(&user.UserId, &user.FullName, &user.WeeklyCapacity, &start, &due, &remaining)
 		if err != nil {
 			return nil, err
 		}
 
-		tasks_list = append(tasks_list, *task)
+		if last := len(matrix.Users) - 1; last < 0 || matrix.Users[last].UserId != user.UserId {
+			matrix.Users = append(matrix.Users, user)
+			minutes = append(minutes, make([]float64, len(matrix.Weeks)))
+		}
+
+		// Users without planned tasks come with an empty task
+		if due == nil || remaining == nil {
+			continue
+		}
+
+		task := plannedTask{start: start, due: *due, remaining: *remaining}
+		for i, week := range matrix.Weeks {
+			lower, upper := weekRange(week, from, to)
+			minutes[len(minutes)-1][i] += task.allocation(lower, upper)
+		}
+	}
+
+	for u := range matrix.Users {
+		user := &matrix.Users[u]
+		user.Weeks = []types.WorkloadCell{}
+
+		for i, week := range matrix.Weeks {
+			lower, upper := weekRange(week, from, to)
+
+			cell := types.WorkloadCell{
+				Week:             week,
+				CapacityMinutes:  capacity(user.WeeklyCapacity, lower, upper),
+				AllocatedMinutes: int(math.Round(minutes[u][i])),
+			}
+
+			cell.OverAllocated = cell.AllocatedMinutes > cell.CapacityMinutes
+			user.Weeks = append(user.Weeks, cell)
+		}
+	}
+
+	return matrix, nil
+}
+
+// Matches the open tasks with a remaining estimate that are planned on at
+// least one day between the SQL dates lower and upper
+func planned(lower string, upper string) string {
+	return "tasks.dueDate >= " + lower + " AND COALESCE(tasks.startDate, tasks.dueDate) <= " + upper + " AND " +
+		"tasks.remainingEstimate IS NOT NULL AND " + workflows.CategoryColumn + " <> 'done'"
+}
+
+// Runs a statement that returns a single user row, such as an INSERT or UPDATE with RETURNING
+func queryUser(tx *sql.Tx, query string, args ...any) (*types.User, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return ScanRowIntoUser(rows)
+}
+
+// Records the change between two versions of a user in the audit trail
+func record(tx *sql.Tx, before *types.User, after *types.User, action types.AuditAction, actorId int) error {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	user := after
+	if user == nil {
+		user = before
 	}
 
-	return tasks_list, nil
+	return audit.Record(tx, types.AuditUser, user.ID, action, actorId, changes)
 }
 
 func ScanRowIntoUser(rows *sql.Rows) (*types.User, error) {
@@ -171,6 +388,7 @@ func ScanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 		&user.Email,
 		&user.RegisterDate,
 		&user.UserRole,
+		&user.WeeklyCapacity,
 	)
 
 	if err != nil {
@@ -0,0 +1,72 @@
+package users
+
+import "github.com/4lerman/pm_service/types"
+
+// plannedTask is the remaining estimate of an open task in minutes, planned
+// from its start to its due date
+type plannedTask struct {
+	start     *types.Date
+	due       types.Date
+	remaining int
+}
+
+// Returns the minutes of the remaining estimate that fall between two days,
+// inclusive. The estimate is spread evenly over the days from the start to
+// the due date; a task without a start date is all planned on its due date.
+func (t plannedTask) allocation(from types.Date, to types.Date) float64 {
+	start := t.due
+	if t.start != nil && t.start.Before(t.due.Time) {
+		start = *t.start
+	}
+
+	lower, upper := from, to
+	if start.After(lower.Time) {
+		lower = start
+	}
+
+	if t.due.Before(upper.Time) {
+		upper = t.due
+	}
+
+	if upper.Before(lower.Time) {
+		return 0
+	}
+
+	return float64(t.remaining) * float64(days(lower, upper)) / float64(days(start, t.due))
+}
+
+// Returns the minutes a user with the given weekly capacity in hours can
+// work between two days, inclusive
+func capacity(weeklyCapacity int, from types.Date, to types.Date) int {
+	return weeklyCapacity * 60 * days(from, to) / 7
+}
+
+// Counts the days between two days, inclusive
+func days(from types.Date, to types.Date) int {
+	return int(to.Sub(from.Time).Hours()/24) + 1
+}
+
+// Returns the Mondays of the weeks that overlap the days between from and to
+func weeks(from types.Date, to types.Date) []types.Date {
+	mondays := []types.Date{}
+	for monday := from.AddDate(0, 0, -(int(from.Weekday())+6)%7); !monday.After(to.Time); monday = monday.AddDate(0, 0, 7) {
+		mondays = append(mondays, types.DateOf(monday))
+	}
+
+	return mondays
+}
+
+// Returns the days of the week starting on the Monday that lie between from
+// and to, so the first and the last week may be partial
+func weekRange(monday types.Date, from types.Date, to types.Date) (types.Date, types.Date) {
+	lower, upper := monday, types.DateOf(monday.AddDate(0, 0, 6))
+	if from.After(lower.Time) {
+		lower = from
+	}
+
+	if to.Before(upper.Time) {
+		upper = to
+	}
+
+	return lower, upper
+}
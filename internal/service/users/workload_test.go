@@ -0,0 +1,102 @@
+package users
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+func day(d int) types.Date {
+	return types.NewDate(2024, 7, d)
+}
+
+func dayRef(d int) *types.Date {
+	date := day(d)
+	return &date
+}
+
+func TestAllocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     plannedTask
+		from, to int
+		want     float64
+	}{
+		{"whole task in range", plannedTask{dayRef(2), day(5), 400}, 1, 31, 400},
+		{"one of four days", plannedTask{dayRef(2), day(5), 400}, 3, 3, 100},
+		{"range starts before the task", plannedTask{dayRef(10), day(19), 600}, 1, 12, 180},
+		{"range crosses the due date", plannedTask{dayRef(10), day(19), 600}, 18, 25, 120},
+		{"range after the due date", plannedTask{dayRef(10), day(19), 600}, 20, 25, 0},
+		{"range before the start", plannedTask{dayRef(10), day(19), 600}, 1, 9, 0},
+		{"no start date, due in range", plannedTask{nil, day(15), 90}, 15, 21, 90},
+		{"no start date, due after range", plannedTask{nil, day(22), 90}, 15, 21, 0},
+		{"start after due date", plannedTask{dayRef(20), day(15), 90}, 15, 15, 90},
+		{"single day task", plannedTask{dayRef(8), day(8), 45}, 1, 31, 45},
+	}
+
+	for _, test := range tests {
+		got := test.task.allocation(day(test.from), day(test.to))
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: allocation = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// The allocations of consecutive ranges add up to the whole estimate
+func TestAllocationAddsUp(t *testing.T) {
+	task := plannedTask{dayRef(3), day(23), 1000}
+
+	total := 0.0
+	for _, week := range weeks(day(1), day(31)) {
+		lower, upper := weekRange(week, day(1), day(31))
+		total += task.allocation(lower, upper)
+	}
+
+	if math.Abs(total-1000) > 1e-9 {
+		t.Errorf("weekly allocations add up to %v, want 1000", total)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		weekly   int
+		from, to int
+		want     int
+	}{
+		{"full week", 40, 1, 7, 2400},
+		{"single day", 35, 3, 3, 300},
+		{"partial week", 40, 5, 7, 1028},
+		{"two weeks", 40, 1, 14, 4800},
+		{"no capacity", 0, 1, 31, 0},
+	}
+
+	for _, test := range tests {
+		if got := capacity(test.weekly, day(test.from), day(test.to)); got != test.want {
+			t.Errorf("%s: capacity = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWeeks(t *testing.T) {
+	// 2024-07-03 is a Wednesday and 2024-07-15 a Monday
+	got := weeks(day(3), day(15))
+	want := []types.Date{day(1), day(8), day(15)}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weeks = %v, want %v", got, want)
+	}
+
+	ranges := [][2]types.Date{}
+	for _, week := range got {
+		lower, upper := weekRange(week, day(3), day(15))
+		ranges = append(ranges, [2]types.Date{lower, upper})
+	}
+
+	wantRanges := [][2]types.Date{{day(3), day(7)}, {day(8), day(14)}, {day(15), day(15)}}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("week ranges = %v, want %v", ranges, wantRanges)
+	}
+}
@@ -0,0 +1,168 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.WorkflowStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.WorkflowStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the routes under the projects router, as every project has its own workflow
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/workflow", h.handleGetWorkflow).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/workflow", h.handleUpdateWorkflow).Methods(http.MethodPut)
+}
+
+// @Summary Get project workflow
+// @Description Get the states a project's tasks can be in and the transitions allowed between them
+// @Tags Workflows
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Success 200 {object} types.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/workflow [get]
+func (h *Handler) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	workflow, err := h.store.GetWorkflow(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get workflow: %v", err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, workflow)
+}
+
+// @Summary Update project workflow
+// @Description Replace the states and transitions of a project's workflow. States are listed in display order and new tasks start in the first one.
+// @Description A state that still has tasks in it cannot be removed, nor moved into or out of the done category. A wip_limit caps the number of tasks in a state, the column of the project's board.
+// @Tags Workflows
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param workflow body types.WorkflowPayload true "Workflow states and transitions"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/workflow [put]
+func (h *Handler) handleUpdateWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ManageWorkflow, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var payload types.WorkflowPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	workflow := types.Workflow{ProjectId: projectId, Transitions: payload.Transitions}
+	for _, state := range payload.States {
+		workflow.States = append(workflow.States, types.WorkflowState{Name: state.Name, Category: state.Category, WipLimit: state.WipLimit})
+	}
+
+	if err := validateWorkflow(workflow); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.UpdateWorkflow(projectId, workflow); err != nil {
+		if errors.Is(err, types.ErrStateInUse) {
+			utils.WriteError(w, http.StatusConflict, err)
+			return
+		}
+
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// State names must be unique and transitions must connect two different, existing states
+func validateWorkflow(workflow types.Workflow) error {
+	names := map[string]bool{}
+	for _, state := range workflow.States {
+		if names[state.Name] {
+			return fmt.Errorf("duplicate state %q", state.Name)
+		}
+
+		names[state.Name] = true
+	}
+
+	transitions := map[types.WorkflowTransition]bool{}
+	for _, transition := range workflow.Transitions {
+		if !names[transition.From] || !names[transition.To] {
+			return fmt.Errorf("transition from %q to %q references an unknown state", transition.From, transition.To)
+		}
+
+		if transition.From == transition.To {
+			return fmt.Errorf("transition from %q to itself", transition.From)
+		}
+
+		if transitions[transition] {
+			return fmt.Errorf("duplicate transition from %q to %q", transition.From, transition.To)
+		}
+
+		transitions[transition] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package workflows
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/lib/pq"
+)
+
+// Default is the workflow every new project starts with
+var Default = types.Workflow{
+	States: []types.WorkflowState{
+		{Name: "new", Category: types.Todo},
+		{Name: "in_process", Category: types.InProgress},
+		{Name: "done", Category: types.Done},
+	},
+	Transitions: []types.WorkflowTransition{
+		{From: "new", To: "in_process"},
+		{From: "in_process", To: "new"},
+		{From: "in_process", To: "done"},
+		{From: "done", To: "in_process"},
+	},
+}
+
+// CategoryColumn is the status category of a task in its project's workflow,
+// for use in queries over the tasks table
+const CategoryColumn = "(SELECT category::text FROM workflow_states " +
+	"WHERE workflow_states.projectId = tasks.projectId AND workflow_states.name = tasks.status)"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) GetWorkflow(projectId int) (*types.Workflow, error) {
+	workflow := &types.Workflow{
+		ProjectId:   projectId,
+		States:      []types.WorkflowState{},
+		Transitions: []types.WorkflowTransition{},
+	}
+
+	rows, err := s.db.Query("SELECT name, category, wipLimit FROM workflow_states WHERE projectId = $1 ORDER BY position", projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		state := types.WorkflowState{}
+		if err := rows.Scan(&state.Name, &state.Category, &state.WipLimit); err != nil {
+			return nil, err
+		}
+
+		workflow.States = append(workflow.States, state)
+	}
+
+	if len(workflow.States) == 0 {
+		return nil, fmt.Errorf("workflow not found")
+	}
+
+	rows, err = s.db.Query("SELECT fromState, toState FROM workflow_transitions WHERE projectId = $1 "+
+		"ORDER BY fromState, toState", projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		transition := types.WorkflowTransition{}
+		if err := rows.Scan(&transition.From, &transition.To); err != nil {
+			return nil, err
+		}
+
+		workflow.Transitions = append(workflow.Transitions, transition)
+	}
+
+	return workflow, nil
+}
+
+// Replaces the project's states and transitions. States that are left out
+// are removed, which fails with ErrStateInUse while tasks are still in them.
+// So does moving a state with tasks into or out of the done category, as the
+// tasks would skip the checks and the completion date of a transition.
+func (s *Store) UpdateWorkflow(projectId int, workflow types.Workflow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	names := make([]string, len(workflow.States))
+	categories := make([]string, len(workflow.States))
+	for i, state := range workflow.States {
+		names[i] = state.Name
+		categories[i] = string(state.Category)
+	}
+
+	var changed []string
+	err = tx.QueryRow("SELECT ARRAY(SELECT workflow_states.name FROM workflow_states "+
+		"JOIN unnest($2::text[], $3::text[]) AS updated (name, category) ON updated.name = workflow_states.name "+
+		"WHERE workflow_states.projectId = $1 AND (workflow_states.category::text = $4) <> (updated.category = $4) "+
+		"AND EXISTS (SELECT 1 FROM tasks WHERE tasks.projectId = $1 AND tasks.status = workflow_states.name) "+
+		"ORDER BY workflow_states.position)", projectId, pq.Array(names), pq.Array(categories), types.Done).Scan(pq.Array(&changed))
+
+	if err != nil {
+		return fmt.Errorf("failed to update workflow: %w", err)
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: states with tasks cannot move into or out of the done category: %s",
+			types.ErrStateInUse, strings.Join(changed, ", "))
+	}
+
+	if err := SaveWorkflow(tx, projectId, workflow); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return fmt.Errorf("%w: move the tasks out of the removed states first", types.ErrStateInUse)
+		}
+
+		return fmt.Errorf("failed to update workflow: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Writes the workflow of a project. Callers run it in a transaction, as the
+// transitions are removed before the states are replaced.
+func SaveWorkflow(db execer, projectId int, workflow types.Workflow) error {
+	names := make([]string, len(workflow.States))
+	for i, state := range workflow.States {
+		names[i] = state.Name
+	}
+
+	if _, err := db.Exec("DELETE FROM workflow_transitions WHERE projectId = $1", projectId); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("DELETE FROM workflow_states WHERE projectId = $1 AND NOT name = ANY($2)",
+		projectId, pq.Array(names))
+
+	if err != nil {
+		return err
+	}
+
+	for position, state := range workflow.States {
+		_, err := db.Exec("INSERT INTO workflow_states (projectId, name, category, position, wipLimit) VALUES ($1, $2, $3, $4, $5) "+
+			"ON CONFLICT (projectId, name) DO UPDATE SET category = EXCLUDED.category, position = EXCLUDED.position, "+
+			"wipLimit = EXCLUDED.wipLimit",
+			projectId, state.Name, state.Category, position, state.WipLimit)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, transition := range workflow.Transitions {
+		_, err := db.Exec("INSERT INTO workflow_transitions (projectId, fromState, toState) VALUES ($1, $2, $3)",
+			projectId, transition.From, transition.To)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
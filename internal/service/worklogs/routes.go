@@ -0,0 +1,368 @@
+package worklogs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/internal/policy"
+	"github.com/4lerman/pm_service/internal/service/auth"
+	"github.com/4lerman/pm_service/types"
+	"github.com/4lerman/pm_service/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store        types.WorklogStore
+	taskStore    types.TaskStore
+	projectStore types.ProjectStore
+}
+
+func NewHandler(store types.WorklogStore, taskStore types.TaskStore, projectStore types.ProjectStore) *Handler {
+	return &Handler{
+		store:        store,
+		taskStore:    taskStore,
+		projectStore: projectStore,
+	}
+}
+
+// Registers the worklog routes under the tasks router
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/worklogs", h.handleListWorklogs).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/worklogs", h.handleCreateWorklog).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/worklogs/{worklogId}", h.handleGetWorklogById).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/worklogs/{worklogId}", h.handleUpdateWorklog).Methods(http.MethodPut)
+	router.HandleFunc("/{id}/worklogs/{worklogId}", h.handleDeleteWorklog).Methods(http.MethodDelete)
+}
+
+func (h *Handler) RegisterUserRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/time-report", h.handleUserTimeReport).Methods(http.MethodGet)
+}
+
+func (h *Handler) RegisterProjectRoutes(router *mux.Router) {
+	router.HandleFunc("/{id}/time-report", h.handleProjectTimeReport).Methods(http.MethodGet)
+}
+
+// @Summary List task worklogs
+// @Description Get the time logged on a task, oldest first
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Success 200 {array} types.Worklog
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/worklogs [get]
+func (h *Handler) handleListWorklogs(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	worklogs, err := h.store.ListTaskWorklogs(task.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, worklogs)
+}
+
+// @Summary Log work
+// @Description Log time the caller spent on a task. The duration is taken off the task's remaining estimate, which does not go below zero.
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param worklog body types.WorklogPayload true "Worklog details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/worklogs [post]
+func (h *Handler) handleCreateWorklog(w http.ResponseWriter, r *http.Request) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.LogWork, task, nil); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	userId := auth.GetUserIdFromContext(r.Context())
+
+	err := h.store.CreateWorklog(types.Worklog{
+		TaskId:          task.ID,
+		UserId:          &userId,
+		StartedAt:       payload.StartedAt,
+		DurationMinutes: payload.DurationMinutes,
+		Note:            payload.Note,
+	}, userId)
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"msg": "Created successfully"})
+}
+
+// @Summary Get worklog by ID
+// @Description Get a single worklog of a task
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param worklogId path int true "Worklog ID"
+// @Success 200 {object} types.Worklog
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/worklogs/{worklogId} [get]
+func (h *Handler) handleGetWorklogById(w http.ResponseWriter, r *http.Request) {
+	_, worklog, ok := h.getWorklog(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, worklog)
+}
+
+// @Summary Update a worklog
+// @Description Change a worklog. The time it took off the task's remaining estimate is given back and its new duration is taken off instead.
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param worklogId path int true "Worklog ID"
+// @Param worklog body types.WorklogPayload true "Worklog details"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/worklogs/{worklogId} [put]
+func (h *Handler) handleUpdateWorklog(w http.ResponseWriter, r *http.Request) {
+	task, worklog, ok := h.getWorklog(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.ManageWorklog, task, worklog.UserId); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	payload, ok := parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.UpdateWorklog(worklog.ID, types.Worklog{
+		StartedAt:       payload.StartedAt,
+		DurationMinutes: payload.DurationMinutes,
+		Note:            payload.Note,
+	}, auth.GetUserIdFromContext(r.Context()))
+
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Updated successfully"})
+}
+
+// @Summary Delete a worklog
+// @Description Delete a worklog. The time it took off the task's remaining estimate is given back.
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Task ID"
+// @Param worklogId path int true "Worklog ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/worklogs/{worklogId} [delete]
+func (h *Handler) handleDeleteWorklog(w http.ResponseWriter, r *http.Request) {
+	task, worklog, ok := h.getWorklog(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authorize(r, policy.ManageWorklog, task, worklog.UserId); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.store.DeleteWorklog(worklog.ID, auth.GetUserIdFromContext(r.Context())); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"msg": "Deleted successfully"})
+}
+
+// @Summary User time report
+// @Description Get the time a user logged between two days, inclusive, broken down by project
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.TimeReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/time-report [get]
+func (h *Handler) handleUserTimeReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	userId, _ := strconv.Atoi(id)
+
+	if err := policy.Authorize(auth.GetUserFromContext(r.Context()), policy.ViewTimeReport, policy.Resource{
+		OwnerId: userId,
+	}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	from, to, err := utils.ParseDateRange(r, 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.store.UserTimeReport(userId, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// @Summary Project time report
+// @Description Get the time logged on a project's tasks between two days, inclusive, broken down by user
+// @Tags Worklogs
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Project ID"
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Success 200 {object} types.TimeReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/time-report [get]
+func (h *Handler) handleProjectTimeReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return
+	}
+
+	projectId, _ := strconv.Atoi(id)
+
+	project, err := h.projectStore.GetProjectById(projectId)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get project by id: %v", err))
+		return
+	}
+
+	if err := policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), policy.ViewTimeReport, project, policy.Resource{}); err != nil {
+		utils.WriteError(w, http.StatusForbidden, err)
+		return
+	}
+
+	from, to, err := utils.ParseDateRange(r, 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.store.ProjectTimeReport(project.ID, from, to)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// Loads the task and the worklog from the path, making sure the worklog is on that task
+func (h *Handler) getWorklog(w http.ResponseWriter, r *http.Request) (*types.Task, *types.Worklog, bool) {
+	task, ok := utils.GetTask(w, r, h.taskStore)
+	if !ok {
+		return nil, nil, false
+	}
+
+	worklogId, _ := strconv.Atoi(mux.Vars(r)["worklogId"])
+
+	worklog, err := h.store.GetWorklogById(worklogId)
+	if err != nil || worklog.TaskId != task.ID {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get worklog by id: worklog not found"))
+		return nil, nil, false
+	}
+
+	return task, worklog, true
+}
+
+// Checks the action against the task's project, its assignee and the worklog's author
+func (h *Handler) authorize(r *http.Request, action policy.Action, task *types.Task, authorId *int) error {
+	project, err := h.projectStore.GetProjectById(task.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	resource := policy.Resource{AssigneeId: task.UserId}
+	if authorId != nil {
+		resource.OwnerId = *authorId
+	}
+
+	return policy.AuthorizeProject(h.projectStore, auth.GetUserFromContext(r.Context()), action, project, resource)
+}
+
+func parsePayload(w http.ResponseWriter, r *http.Request) (*types.WorklogPayload, bool) {
+	var payload types.WorklogPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return nil, false
+	}
+
+	return &payload, true
+}
@@ -0,0 +1,257 @@
+package worklogs
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/4lerman/pm_service/internal/service/tasks"
+	"github.com/4lerman/pm_service/types"
+)
+
+// Columns is the select list ScanRowIntoWorklog expects
+const Columns = "id, taskId, userId, startedAt, durationMinutes, note, createdAt, updatedAt"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) ListTaskWorklogs(taskId int) ([]types.Worklog, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM worklogs WHERE taskId = $1 ORDER BY startedAt, id", taskId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	worklogs := []types.Worklog{}
+	for rows.Next() {
+		worklog, err := ScanRowIntoWorklog(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		worklogs = append(worklogs, *worklog)
+	}
+
+	return worklogs, nil
+}
+
+func (s *Store) GetWorklogById(worklogId int) (*types.Worklog, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM worklogs WHERE id = $1", worklogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	worklog := new(types.Worklog)
+	for rows.Next() {
+		worklog, err = ScanRowIntoWorklog(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if worklog.ID == 0 {
+		return nil, fmt.Errorf("worklog not found")
+	}
+
+	return worklog, nil
+}
+
+// Logs time on a task and takes it off the task's remaining estimate
+func (s *Store) CreateWorklog(worklog types.Worklog, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = tasks.Track(tx, worklog.TaskId, actorId, func(task *types.Task) error {
+		deducted, err := adjustRemaining(tx, task, 0, worklog.DurationMinutes)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO worklogs (taskId, userId, startedAt, durationMinutes, note, deductedMinutes) "+
+			"VALUES ($1, $2, $3, $4, $5, $6)",
+			worklog.TaskId, worklog.UserId, worklog.StartedAt, worklog.DurationMinutes, worklog.Note, deducted)
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create worklog: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Changes a worklog. The minutes it took off the task's remaining estimate
+// are given back and its new duration is taken off instead.
+func (s *Store) UpdateWorklog(worklogId int, worklog types.Worklog, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var taskId, previous int
+	err = tx.QueryRow("SELECT taskId, deductedMinutes FROM worklogs WHERE id = $1 FOR UPDATE", worklogId).Scan(&taskId, &previous)
+
+	if err != nil {
+		return fmt.Errorf("failed to update worklog: %w", err)
+	}
+
+	err = tasks.Track(tx, taskId, actorId, func(task *types.Task) error {
+		deducted, err := adjustRemaining(tx, task, previous, worklog.DurationMinutes)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE worklogs SET startedAt = $1, durationMinutes = $2, note = $3, deductedMinutes = $4, "+
+			"updatedAt = NOW() WHERE id = $5",
+			worklog.StartedAt, worklog.DurationMinutes, worklog.Note, deducted, worklogId)
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update worklog: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a worklog and gives the minutes it took off the task's remaining
+// estimate back
+func (s *Store) DeleteWorklog(worklogId int, actorId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var taskId, deducted int
+	err = tx.QueryRow("SELECT taskId, deductedMinutes FROM worklogs WHERE id = $1 FOR UPDATE", worklogId).Scan(&taskId, &deducted)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete worklog: %w", err)
+	}
+
+	err = tasks.Track(tx, taskId, actorId, func(task *types.Task) error {
+		if _, err := adjustRemaining(tx, task, deducted, 0); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("DELETE FROM worklogs WHERE id = $1", worklogId)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to delete worklog: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Sums up the time a user logged between two days, by project
+func (s *Store) UserTimeReport(userId int, from types.Date, to types.Date) (*types.TimeReport, error) {
+	return s.timeReport("SELECT projects.id, projects.title, SUM(worklogs.durationMinutes) "+
+		"FROM worklogs JOIN tasks ON tasks.id = worklogs.taskId JOIN projects ON projects.id = tasks.projectId "+
+		"WHERE worklogs.userId = $1 AND "+inRange+
+		"GROUP BY projects.id ORDER BY projects.title, projects.id", userId, from, to)
+}
+
+// Sums up the time logged on a project's tasks between two days, by user.
+// Time logged by users that have since been deleted is grouped under id 0.
+func (s *Store) ProjectTimeReport(projectId int, from types.Date, to types.Date) (*types.TimeReport, error) {
+	return s.timeReport("SELECT COALESCE(users.id, 0), COALESCE(users.fullName, ''), SUM(worklogs.durationMinutes) "+
+		"FROM worklogs JOIN tasks ON tasks.id = worklogs.taskId LEFT JOIN users ON users.id = worklogs.userId "+
+		"WHERE tasks.projectId = $1 AND "+inRange+
+		"GROUP BY users.id ORDER BY COALESCE(users.fullName, ''), users.id", projectId, from, to)
+}
+
+// inRange matches worklogs started on or after the day $2 and before the end
+// of the day $3
+const inRange = "worklogs.startedAt >= $2::date AND worklogs.startedAt < $3::date + 1 "
+
+func (s *Store) timeReport(query string, id int, from types.Date, to types.Date) (*types.TimeReport, error) {
+	rows, err := s.db.Query(query, id, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	report := &types.TimeReport{
+		From:   from,
+		To:     to,
+		Groups: []types.TimeGroup{},
+	}
+
+	for rows.Next() {
+		var group types.TimeGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.Minutes); err != nil {
+			return nil, err
+		}
+
+		report.TotalMinutes += group.Minutes
+		report.Groups = append(report.Groups, group)
+	}
+
+	return report, nil
+}
+
+// Gives back the minutes a worklog took off the task's remaining estimate and
+// takes off the minutes it logs now, never going below zero. Returns the
+// minutes actually taken off, which is what a later change gives back. Tasks
+// without an estimate are left alone.
+func adjustRemaining(tx *sql.Tx, task *types.Task, giveBack int, take int) (int, error) {
+	if task.RemainingEstimate == nil {
+		return 0, nil
+	}
+
+	available := *task.RemainingEstimate + giveBack
+	deducted := min(available, take)
+
+	_, err := tx.Exec("UPDATE tasks SET remainingEstimate = $1 WHERE id = $2", available-deducted, task.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust remaining estimate: %w", err)
+	}
+
+	return deducted, nil
+}
+
+func ScanRowIntoWorklog(rows *sql.Rows) (*types.Worklog, error) {
+	worklog := new(types.Worklog)
+
+	err := rows.Scan(
+		&worklog.ID,
+		&worklog.TaskId,
+		&worklog.UserId,
+		&worklog.StartedAt,
+		&worklog.DurationMinutes,
+		&worklog.Note,
+		&worklog.CreatedAt,
+		&worklog.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return worklog, nil
+}
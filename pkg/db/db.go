@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
@@ -30,3 +31,8 @@ func NewPSQLStorage(cfg *DbConfig) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// Escapes the wildcards of a LIKE pattern so that value matches literally
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,322 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Keyset maps the fields a listing can be sorted by to their SQL expressions.
+// The "id" field is always appended as the last sort key so that every row
+// has a unique position and cursors stay stable between pages.
+type Keyset map[string]string
+
+// PageQuery is the filtered row set to paginate. Where may reference Args
+// with $1..$n placeholders; Select defaults to "*". Nullable lists the sort
+// fields whose expressions can be NULL.
+type PageQuery struct {
+	Select   string
+	From     string
+	Where    string
+	Args     []any
+	Nullable []string
+}
+
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Runs a keyset paginated query and returns the requested page together with
+// the total number of rows matching the filter
+func Paginate[T any](conn *sql.DB, q PageQuery, keyset Keyset, params types.ListParams, scan func(*sql.Rows) (*T, error)) (*types.Page[T], error) {
+	sortKeys, err := resolveSort(keyset, params.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	selectList := q.Select
+	if selectList == "" {
+		selectList = "*"
+	}
+
+	args := append([]any{}, q.Args...)
+	conditions := []string{}
+	if q.Where != "" {
+		conditions = append(conditions, "("+q.Where+")")
+	}
+
+	total := 0
+	countQuery := "SELECT COUNT(*) FROM " + q.From + whereClause(conditions)
+	if err := conn.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	if params.Cursor != "" {
+		values, err := decodeCursor(params.Cursor, sortKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		condition, cursorArgs := keysetCondition(keyset, q.Nullable, sortKeys, values, len(args))
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	orderBy := make([]string, len(sortKeys))
+	for i, key := range sortKeys {
+		orderBy[i] = keyset[key.Field] + direction(key.Desc)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d",
+		selectList, q.From, whereClause(conditions), strings.Join(orderBy, ", "), limit+1)
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, *item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &types.Page[T]{Items: items, Total: total}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor, err = encodeCursor(page.Items[limit-1], sortKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func resolveSort(keyset Keyset, sort []types.SortKey) ([]types.SortKey, error) {
+	keys := []types.SortKey{}
+	hasId := false
+
+	for _, key := range sort {
+		if _, ok := keyset[key.Field]; !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", types.ErrInvalidListParams, key.Field)
+		}
+
+		if key.Field == "id" {
+			hasId = true
+		}
+
+		keys = append(keys, key)
+	}
+
+	if !hasId {
+		keys = append(keys, types.SortKey{Field: "id"})
+	}
+
+	return keys, nil
+}
+
+// Builds "rows after the cursor" for an arbitrary mix of sort directions:
+// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND id > $3)
+//
+// Postgres sorts NULLs as larger than any value, so they come last in
+// ascending and first in descending order; the terms for nullable keys
+// follow that, and NULL cursor values are matched with IS NULL.
+func keysetCondition(keyset Keyset, nullable []string, keys []types.SortKey, values []json.RawMessage, offset int) (string, []any) {
+	args := []any{}
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		if value := cursorValue(values[i]); value != nil {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", offset+len(args))
+		}
+	}
+
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		terms := []string{}
+		for j := 0; j < i; j++ {
+			terms = append(terms, equalTerm(keyset[keys[j].Field], placeholders[j]))
+		}
+
+		terms = append(terms, afterTerm(keyset[key.Field], placeholders[i], key.Desc, slices.Contains(nullable, key.Field)))
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func equalTerm(column string, placeholder string) string {
+	if placeholder == "" {
+		return column + " IS NULL"
+	}
+
+	return fmt.Sprintf("%s = %s", column, placeholder)
+}
+
+// Values that sort after the cursor value in the given direction
+func afterTerm(column string, placeholder string, desc bool, nullable bool) string {
+	switch {
+	case placeholder == "" && desc:
+		return column + " IS NOT NULL"
+	case placeholder == "":
+		return "FALSE"
+	case desc:
+		return fmt.Sprintf("%s < %s", column, placeholder)
+	case nullable:
+		return fmt.Sprintf("(%s > %s OR %s IS NULL)", column, placeholder, column)
+	}
+
+	return fmt.Sprintf("%s > %s", column, placeholder)
+}
+
+func encodeCursor(item any, keys []types.SortKey) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+
+	c := cursor{Sort: sortSignature(keys)}
+	for _, key := range keys {
+		value, err := lookupField(fields, key.Field)
+		if err != nil {
+			return "", err
+		}
+
+		c.Values = append(c.Values, value)
+	}
+
+	data, err = json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Finds a field of the item's JSON. Dotted names such as "custom_fields.points"
+// reach into nested objects, where a missing entry stands for NULL.
+func lookupField(fields map[string]json.RawMessage, name string) (json.RawMessage, error) {
+	if value, ok := fields[name]; ok {
+		return value, nil
+	}
+
+	parent, child, nested := strings.Cut(name, ".")
+	if !nested {
+		return nil, fmt.Errorf("cannot build cursor: field %q is missing", name)
+	}
+
+	object, ok := fields[parent]
+	if !ok {
+		return nil, fmt.Errorf("cannot build cursor: field %q is missing", parent)
+	}
+
+	children := map[string]json.RawMessage{}
+	if err := json.Unmarshal(object, &children); err != nil {
+		return nil, fmt.Errorf("cannot build cursor: field %q is not an object", parent)
+	}
+
+	value, ok := children[child]
+	if !ok {
+		return json.RawMessage("null"), nil
+	}
+
+	return value, nil
+}
+
+func decodeCursor(encoded string, keys []types.SortKey) ([]json.RawMessage, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", types.ErrInvalidListParams)
+	}
+
+	c := cursor{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", types.ErrInvalidListParams)
+	}
+
+	if c.Sort != sortSignature(keys) || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: cursor does not match the requested sort", types.ErrInvalidListParams)
+	}
+
+	return c.Values, nil
+}
+
+// Numbers and strings are passed to postgres as text so that it can coerce
+// them to the column type, which also covers timestamps
+func cursorValue(raw json.RawMessage) any {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+
+	if number, ok := value.(json.Number); ok {
+		return number.String()
+	}
+
+	return value
+}
+
+func sortSignature(keys []types.SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Field + direction(key.Desc)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+
+	return " ASC"
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
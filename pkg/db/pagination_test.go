@@ -0,0 +1,287 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+var keyset = Keyset{
+	"id":         "tasks.id",
+	"title":      "tasks.title",
+	"due_date":   "tasks.dueDate",
+	"created_at": "tasks.createdAt",
+}
+
+type item struct {
+	ID           int            `json:"id"`
+	Title        string         `json:"title"`
+	DueDate      *string        `json:"due_date"`
+	CustomFields map[string]int `json:"custom_fields"`
+}
+
+func TestResolveSort(t *testing.T) {
+	tests := []struct {
+		sort []types.SortKey
+		want []types.SortKey
+	}{
+		{nil, []types.SortKey{{Field: "id"}}},
+		{
+			[]types.SortKey{{Field: "title", Desc: true}},
+			[]types.SortKey{{Field: "title", Desc: true}, {Field: "id"}},
+		},
+		{
+			[]types.SortKey{{Field: "id", Desc: true}, {Field: "title"}},
+			[]types.SortKey{{Field: "id", Desc: true}, {Field: "title"}},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := resolveSort(keyset, test.sort)
+		if err != nil {
+			t.Errorf("resolveSort(%v) error: %v", test.sort, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("resolveSort(%v) = %v, want %v", test.sort, got, test.want)
+		}
+	}
+
+	_, err := resolveSort(keyset, []types.SortKey{{Field: "password"}})
+	if !errors.Is(err, types.ErrInvalidListParams) {
+		t.Errorf("resolveSort(password) = %v, want ErrInvalidListParams", err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	due := "2024-05-01"
+	keys := []types.SortKey{{Field: "due_date", Desc: true}, {Field: "title"}, {Field: "id"}}
+
+	tests := []struct {
+		item item
+		want []string
+	}{
+		{item{ID: 12, Title: "a \"quoted\" title", DueDate: &due}, []string{`"2024-05-01"`, `"a \"quoted\" title"`, "12"}},
+		{item{ID: 3, Title: ""}, []string{"null", `""`, "3"}},
+	}
+
+	for _, test := range tests {
+		encoded, err := encodeCursor(test.item, keys)
+		if err != nil {
+			t.Errorf("encodeCursor(%v) error: %v", test.item, err)
+			continue
+		}
+
+		values, err := decodeCursor(encoded, keys)
+		if err != nil {
+			t.Errorf("decodeCursor(%q) error: %v", encoded, err)
+			continue
+		}
+
+		got := make([]string, len(values))
+		for i, value := range values {
+			got[i] = string(value)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("cursor of %v = %v, want %v", test.item, got, test.want)
+		}
+	}
+}
+
+func TestEncodeCursorMissingField(t *testing.T) {
+	if _, err := encodeCursor(item{ID: 1}, []types.SortKey{{Field: "rank"}}); err == nil {
+		t.Error("encodeCursor with an unknown field succeeded, want an error")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	keys := []types.SortKey{{Field: "title"}, {Field: "id"}}
+
+	valid, err := encodeCursor(item{ID: 1, Title: "a"}, keys)
+	if err != nil {
+		t.Fatalf("encodeCursor error: %v", err)
+	}
+
+	encode := func(c cursor) string {
+		data, _ := json.Marshal(c)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		keys    []types.SortKey
+	}{
+		{"not base64", "not a cursor!", keys},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("{s:")), keys},
+		{"truncated", valid[:len(valid)-4], keys},
+		{"other sort", valid, []types.SortKey{{Field: "title", Desc: true}, {Field: "id"}}},
+		{"other fields", valid, []types.SortKey{{Field: "id"}}},
+		{"tampered signature", encode(cursor{Sort: "id DESC", Values: []json.RawMessage{json.RawMessage("1")}}), []types.SortKey{{Field: "id"}}},
+		{"missing values", encode(cursor{Sort: sortSignature(keys), Values: []json.RawMessage{json.RawMessage(`"a"`)}}), keys},
+	}
+
+	for _, test := range tests {
+		if _, err := decodeCursor(test.encoded, test.keys); !errors.Is(err, types.ErrInvalidListParams) {
+			t.Errorf("%s: decodeCursor = %v, want ErrInvalidListParams", test.name, err)
+		}
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	fields := map[string]json.RawMessage{}
+	data, _ := json.Marshal(item{ID: 1, Title: "a", CustomFields: map[string]int{"points": 5}})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"id", "1"},
+		{"custom_fields.points", "5"},
+		{"custom_fields.effort", "null"},
+	}
+
+	for _, test := range tests {
+		got, err := lookupField(fields, test.name)
+		if err != nil {
+			t.Errorf("lookupField(%q) error: %v", test.name, err)
+			continue
+		}
+
+		if string(got) != test.want {
+			t.Errorf("lookupField(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	for _, name := range []string{"rank", "labels.points", "title.points"} {
+		if _, err := lookupField(fields, name); err == nil {
+			t.Errorf("lookupField(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestCursorValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want any
+	}{
+		{"12", "12"},
+		{"12345678901234567890", "12345678901234567890"},
+		{"1.5", "1.5"},
+		{`"2024-05-01T09:30:00Z"`, "2024-05-01T09:30:00Z"},
+		{"true", true},
+		{"null", nil},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		if got := cursorValue(json.RawMessage(test.raw)); got != test.want {
+			t.Errorf("cursorValue(%s) = %#v, want %#v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestAfterTerm(t *testing.T) {
+	tests := []struct {
+		placeholder string
+		desc        bool
+		nullable    bool
+		want        string
+	}{
+		{"$1", false, false, "c > $1"},
+		{"$1", true, false, "c < $1"},
+
+		// NULLs come last in ascending and first in descending order
+		{"$1", false, true, "(c > $1 OR c IS NULL)"},
+		{"$1", true, true, "c < $1"},
+		{"", false, true, "FALSE"},
+		{"", true, true, "c IS NOT NULL"},
+	}
+
+	for _, test := range tests {
+		if got := afterTerm("c", test.placeholder, test.desc, test.nullable); got != test.want {
+			t.Errorf("afterTerm(%q, desc %v, nullable %v) = %q, want %q",
+				test.placeholder, test.desc, test.nullable, got, test.want)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	nullable := []string{"due_date"}
+
+	tests := []struct {
+		name   string
+		keys   []types.SortKey
+		values []string
+		where  string
+		args   []any
+	}{
+		{
+			"tie-break on id",
+			[]types.SortKey{{Field: "title"}, {Field: "id"}},
+			[]string{`"a"`, "7"},
+			"((tasks.title > $3) OR (tasks.title = $3 AND tasks.id > $4))",
+			[]any{"a", "7"},
+		},
+		{
+			"mixed directions",
+			[]types.SortKey{{Field: "created_at", Desc: true}, {Field: "id"}},
+			[]string{`"2024-05-01T09:30:00Z"`, "7"},
+			"((tasks.createdAt < $3) OR (tasks.createdAt = $3 AND tasks.id > $4))",
+			[]any{"2024-05-01T09:30:00Z", "7"},
+		},
+		{
+			"nullable ascending",
+			[]types.SortKey{{Field: "due_date"}, {Field: "id"}},
+			[]string{`"2024-05-01"`, "7"},
+			"(((tasks.dueDate > $3 OR tasks.dueDate IS NULL)) OR (tasks.dueDate = $3 AND tasks.id > $4))",
+			[]any{"2024-05-01", "7"},
+		},
+		{
+			"nullable descending",
+			[]types.SortKey{{Field: "due_date", Desc: true}, {Field: "id"}},
+			[]string{`"2024-05-01"`, "7"},
+			"((tasks.dueDate < $3) OR (tasks.dueDate = $3 AND tasks.id > $4))",
+			[]any{"2024-05-01", "7"},
+		},
+		{
+			"null cursor ascending",
+			[]types.SortKey{{Field: "due_date"}, {Field: "id"}},
+			[]string{"null", "7"},
+			"((FALSE) OR (tasks.dueDate IS NULL AND tasks.id > $3))",
+			[]any{"7"},
+		},
+		{
+			"null cursor descending",
+			[]types.SortKey{{Field: "due_date", Desc: true}, {Field: "id", Desc: true}},
+			[]string{"null", "7"},
+			"((tasks.dueDate IS NOT NULL) OR (tasks.dueDate IS NULL AND tasks.id < $3))",
+			[]any{"7"},
+		},
+	}
+
+	for _, test := range tests {
+		values := make([]json.RawMessage, len(test.values))
+		for i, value := range test.values {
+			values[i] = json.RawMessage(value)
+		}
+
+		where, args := keysetCondition(keyset, nullable, test.keys, values, 2)
+		if where != test.where {
+			t.Errorf("%s: condition = %q, want %q", test.name, where, test.where)
+		}
+
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: args = %v, want %v", test.name, args, test.args)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package rank
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ranks are base 36 strings ordered byte by byte. Items appended at the end
+// or put in front get a fixed width counter followed by a fraction digit, so
+// that neither makes ranks longer; the first item starts halfway through the
+// counter range. Items placed between two others take the midpoint of their
+// neighbours. A rank never ends in '0', which leaves room below every rank.
+const (
+	digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	width  = 6
+)
+
+// Returns the rank of an item appended after the given rank, or of the first
+// item when rank is empty
+func After(rank string) string {
+	if rank == "" {
+		return "i00000i"
+	}
+
+	n, ok := counter(rank)
+	if next, fits := format(n + 1); ok && fits {
+		return next
+	}
+
+	return midpoint(rank, "")
+}
+
+// Returns the rank of an item put in front of the given rank
+func Before(rank string) string {
+	n, ok := counter(rank)
+	if ok && n > 0 {
+		if previous, fits := format(n - 1); fits {
+			return previous
+		}
+	}
+
+	return midpoint("", rank)
+}
+
+// Reads the fixed width counter a rank starts with
+func counter(rank string) (uint64, bool) {
+	prefix := rank
+	if len(prefix) > width {
+		prefix = prefix[:width]
+	}
+
+	n, err := strconv.ParseUint(prefix+strings.Repeat("0", width-len(prefix)), len(digits), 64)
+	return n, err == nil
+}
+
+func format(n uint64) (string, bool) {
+	value := strconv.FormatUint(n, len(digits))
+	if len(value) > width {
+		return "", false
+	}
+
+	return strings.Repeat("0", width-len(value)) + value + "i", true
+}
+
+// Returns a rank that sorts after lower and before upper. An empty lower
+// means the start and an empty upper the end.
+func Between(lower string, upper string) (string, error) {
+	if err := validate(lower); err != nil {
+		return "", err
+	}
+
+	if err := validate(upper); err != nil {
+		return "", err
+	}
+
+	if upper == "" {
+		return After(lower), nil
+	}
+
+	if lower == "" {
+		return Before(upper), nil
+	}
+
+	if lower >= upper {
+		return "", fmt.Errorf("rank %q is not before %q", lower, upper)
+	}
+
+	return midpoint(lower, upper), nil
+}
+
+func validate(rank string) error {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(digits, rank[i]) < 0 {
+			return fmt.Errorf("invalid rank %q", rank)
+		}
+	}
+
+	if strings.HasSuffix(rank, "0") {
+		return fmt.Errorf("invalid rank %q: ends in 0", rank)
+	}
+
+	return nil
+}
+
+// Finds the shortest string between lower and upper, with an empty upper
+// standing for the end. Lower is read as if padded with zeros.
+func midpoint(lower string, upper string) string {
+	if upper != "" {
+		n := 0
+		for n < len(upper) && digitAt(lower, n) == upper[n] {
+			n++
+		}
+
+		if n > 0 {
+			return upper[:n] + midpoint(suffix(lower, n), upper[n:])
+		}
+	}
+
+	lo := strings.IndexByte(digits, digitAt(lower, 0))
+	hi := len(digits)
+	if upper != "" {
+		hi = strings.IndexByte(digits, upper[0])
+	}
+
+	if hi-lo > 1 {
+		return string(digits[(lo+hi)/2])
+	}
+
+	if len(upper) > 1 {
+		return upper[:1]
+	}
+
+	return string(digits[lo]) + midpoint(suffix(lower, 1), "")
+}
+
+func digitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+
+	return digits[0]
+}
+
+func suffix(rank string, i int) string {
+	if i < len(rank) {
+		return rank[i:]
+	}
+
+	return ""
+}
@@ -0,0 +1,147 @@
+package rank
+
+import (
+	"strings"
+	"testing"
+)
+
+func valid(t *testing.T, rank string) {
+	t.Helper()
+
+	if err := validate(rank); err != nil || rank == "" {
+		t.Fatalf("rank %q is not valid: %v", rank, err)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		rank string
+		want string
+	}{
+		{"", "i00000i"},
+		{"i00000i", "i00001i"},
+		{"i0000zi", "i00010i"},
+		{"i00000i5", "i00001i"},
+		{"i", "i00001i"},
+	}
+
+	for _, test := range tests {
+		if got := After(test.rank); got != test.want {
+			t.Errorf("After(%q) = %q, want %q", test.rank, got, test.want)
+		}
+	}
+
+	// Past the end of the counter range ranks grow instead
+	last := "zzzzzzi"
+	next := After(last)
+	valid(t, next)
+
+	if next <= last {
+		t.Errorf("After(%q) = %q, want a later rank", last, next)
+	}
+}
+
+func TestBefore(t *testing.T) {
+	tests := []struct {
+		rank string
+		want string
+	}{
+		{"i00000i", "hzzzzzi"},
+		{"i00001i", "i00000i"},
+	}
+
+	for _, test := range tests {
+		if got := Before(test.rank); got != test.want {
+			t.Errorf("Before(%q) = %q, want %q", test.rank, got, test.want)
+		}
+	}
+
+	// Below the start of the counter range ranks grow instead
+	for _, first := range []string{"000000i", "000000005", "1"} {
+		previous := Before(first)
+		valid(t, previous)
+
+		if previous >= first {
+			t.Errorf("Before(%q) = %q, want an earlier rank", first, previous)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		lower string
+		upper string
+		want  string
+	}{
+		{"", "", "i00000i"},
+		{"i00000i", "", "i00001i"},
+		{"", "i00000i", "hzzzzzi"},
+		{"a", "c", "b"},
+		{"a", "b", "ai"},
+		{"a", "a1", "a0i"},
+		{"i00000i", "i00001i", "i00001"},
+		{"az", "b", "azi"},
+	}
+
+	for _, test := range tests {
+		got, err := Between(test.lower, test.upper)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error: %v", test.lower, test.upper, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", test.lower, test.upper, got, test.want)
+		}
+	}
+}
+
+func TestBetweenErrors(t *testing.T) {
+	tests := []struct {
+		lower string
+		upper string
+	}{
+		{"b", "a"},
+		{"a", "a"},
+		{"A", ""},
+		{"", "a-b"},
+		{"a0", "b"},
+	}
+
+	for _, test := range tests {
+		if got, err := Between(test.lower, test.upper); err == nil {
+			t.Errorf("Between(%q, %q) = %q, want an error", test.lower, test.upper, got)
+		}
+	}
+}
+
+// Repeatedly placing an item right after the same neighbour, or right before
+// it, must always find room and keep the order
+func TestBetweenRepeated(t *testing.T) {
+	for _, start := range [][2]string{{"i00000i", "i00001i"}, {"", "1"}, {"zzzzzzi", ""}} {
+		lower, upper := start[0], start[1]
+
+		for i := 0; i < 200; i++ {
+			middle, err := Between(lower, upper)
+			if err != nil {
+				t.Fatalf("Between(%q, %q) error: %v", lower, upper, err)
+			}
+
+			valid(t, middle)
+
+			if middle <= lower || (upper != "" && middle >= upper) {
+				t.Fatalf("Between(%q, %q) = %q, out of order", lower, upper, middle)
+			}
+
+			if i%2 == 0 {
+				upper = middle
+			} else {
+				lower = middle
+			}
+		}
+
+		if len(lower) > 200 || strings.HasSuffix(lower, "0") {
+			t.Errorf("ranks grew to %q", lower)
+		}
+	}
+}
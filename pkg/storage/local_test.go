@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newLocal(t *testing.T) (*LocalStorage, string) {
+	t.Helper()
+
+	root := filepath.Join(t.TempDir(), "files")
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("NewLocalStorage error: %v", err)
+	}
+
+	return s, root
+}
+
+func read(t *testing.T, s Storage, key string) string {
+	t.Helper()
+
+	body, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q) error: %v", key, err)
+	}
+
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+
+	return string(data)
+}
+
+func TestLocalStorage(t *testing.T) {
+	s, root := newLocal(t)
+	ctx := context.Background()
+	key := "tasks/7/report.txt"
+
+	if err := s.Put(ctx, key, strings.NewReader("first"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	if got := read(t, s, key); got != "first" {
+		t.Errorf("Get = %q, want %q", got, "first")
+	}
+
+	// Putting the same key again replaces the object
+	if err := s.Put(ctx, key, strings.NewReader("second"), 6, "text/plain"); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	if got := read(t, s, key); got != "second" {
+		t.Errorf("Get = %q, want %q", got, "second")
+	}
+
+	// No temporary files are left next to the object
+	entries, err := os.ReadDir(filepath.Join(root, "tasks", "7"))
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want 1", len(entries))
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+
+	// Deleting a missing object is not an error
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object = %v, want nil", err)
+	}
+}
+
+func TestLocalStorageFailedPut(t *testing.T) {
+	s, root := newLocal(t)
+	ctx := context.Background()
+
+	body := io.MultiReader(strings.NewReader("partial"), failingReader{})
+	if err := s.Put(ctx, "broken.txt", body, 100, "text/plain"); err == nil {
+		t.Fatal("Put with a failing body succeeded, want an error")
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("a failed Put left %d entries behind", len(entries))
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestLocalStorageRejectsInvalidKeys(t *testing.T) {
+	s, root := newLocal(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"../outside.txt", "a/../../outside.txt", "/etc/passwd", "", ".", "a/.."} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", key)
+		}
+
+		if _, err := s.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an error", key)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "outside.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written outside the root: %v", err)
+	}
+}
+
+type recordingStorage struct {
+	Storage
+	deleted []string
+}
+
+func (s *recordingStorage) Delete(_ context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	if key == "fails" {
+		return errors.New("unavailable")
+	}
+
+	return nil
+}
+
+func TestDeleteAll(t *testing.T) {
+	s := &recordingStorage{}
+	DeleteAll(context.Background(), s, []string{"a", "fails", "b"})
+
+	if got := strings.Join(s.deleted, ","); got != "a,fails,b" {
+		t.Errorf("deleted %s, want every key despite a failure", got)
+	}
+}
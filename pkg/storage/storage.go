@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded files as objects addressed by slash separated keys
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Removes the objects of the keys, for use once their metadata is gone. A
+// failure only leaves an unreachable object behind, so it is logged.
+func DeleteAll(ctx context.Context, s Storage, keys []string) {
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			log.Println("Failed to remove attachment content", key, err)
+		}
+	}
+}
+
+type StorageConfig struct {
+	Driver      string
+	LocalPath   string
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3UseSSL    bool
+}
+
+// Creates the storage selected by cfg.Driver, "local" or "s3"
+func NewStorage(cfg *StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath)
+	case "s3":
+		return NewS3Storage(cfg)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
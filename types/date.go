@@ -0,0 +1,69 @@
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date is a calendar day without a time of day, written as YYYY-MM-DD in JSON
+// and stored in DATE columns
+type Date struct {
+	time.Time
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// Returns the day t falls on in UTC
+func DateOf(t time.Time) Date {
+	return NewDate(t.UTC().Date())
+}
+
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD", value)
+	}
+
+	return Date{t}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(time.DateOnly)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return fmt.Errorf("invalid date %s: expected a YYYY-MM-DD string", data)
+	}
+
+	date, err := ParseDate(value[1 : len(value)-1])
+	if err != nil {
+		return err
+	}
+
+	*d = date
+	return nil
+}
+
+func (d *Date) Scan(src any) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into a date", src)
+	}
+
+	*d = NewDate(t.Date())
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
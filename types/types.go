@@ -1,35 +1,277 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidListParams = errors.New("invalid list parameters")
+	ErrIllegalTransition = errors.New("transition not allowed")
+	ErrStateInUse        = errors.New("workflow state is in use")
+	ErrInvalidParent     = errors.New("invalid parent task")
+	ErrParentDone        = errors.New("parent task is done")
+	ErrOpenSubtasks      = errors.New("task has open subtasks")
+	ErrHasSubtasks       = errors.New("task has subtasks")
+	ErrTaskBlocked       = errors.New("task is blocked")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+	ErrLinkExists        = errors.New("link already exists")
+	ErrLabelExists       = errors.New("label already exists")
+	ErrFieldExists       = errors.New("custom field already exists")
+	ErrOptionInUse       = errors.New("option is in use")
+	ErrInvalidFieldValue = errors.New("invalid custom field value")
+	ErrSprintActive      = errors.New("project already has an active sprint")
+	ErrSprintState       = errors.New("not allowed in the sprint's state")
+	ErrSprintNotFound    = errors.New("sprint not found")
+	ErrWipLimit          = errors.New("WIP limit reached")
+	ErrInvalidMove       = errors.New("invalid move")
+	ErrTeamExists        = errors.New("team already exists")
+	ErrFilterExists      = errors.New("filter already exists")
+	ErrManagerRole       = errors.New("the project manager must remain an owner")
+)
 
+// Methods that change users, tasks and projects take the id of the acting
+// user as their last argument and record it in the audit trail
 type UserStore interface {
-	ListUsers() ([]User, error)
-	CreateUser(User) error
+	ListUsers(ListParams) (*Page[User], error)
+	CreateUser(User, string, int) error
 	GetUserById(int) (*User, error)
 	GetUsersByEmail(string) ([]User, error)
 	GetUsersByName(string) ([]User, error)
-	UpdateUser(int, User) error
-	DeleteUser(int) error
-	GetUserTasks(int) ([]Task, error)
+	UpdateUser(int, User, int) error
+	DeleteUser(int, int) error
+	GetUserTasks(int, *time.Time, ListParams) (*Page[Task], error)
+	GetUserWorkload(int, Date, Date) (*Workload, error)
+	GetWorkloadMatrix(*int, *int, Date, Date) (*WorkloadMatrix, error)
 }
 
 type TaskStore interface {
-	ListTasks() ([]Task, error)
-	CreateTask(Task) error
+	ListTasks(ListParams) (*Page[Task], error)
+	CreateTask(Task, int) error
 	GetTaskById(int) (*Task, error)
-	GetTasksByQuery(string, string) ([]Task, error)
-	UpdateTask(int, Task) error
-	DeleteTask(int) error
+	SearchTasks(TaskFilter, ListParams) (*Page[Task], error)
+	QueryTasks(TaskQuery, ListParams) (*Page[Task], error)
+	UpdateTask(int, Task, int) error
+	SetFieldValues(int, map[int]any, int) error
+	TransitionTask(int, string, string, int) error
+	MoveTask(int, TaskMove, int) error
+	DeleteTask(int, int) ([]string, error)
+	GetTaskChildren(int, ListParams) (*Page[Task], error)
+	GetTaskTree(int) (*TaskTree, error)
+	ListOverdueTasks(ListParams) (*Page[Task], error)
 }
 
 type ProjectStore interface {
-	ListProjects() ([]Project, error)
-	CreateProject(Project) error
+	ListProjects(ListParams) (*Page[Project], error)
+	CreateProject(Project, int) error
 	GetProjectById(int) (*Project, error)
 	GetProjectsByQuery(string, string) ([]Project, error)
-	UpdateProject(int, Project) error
-	DeleteProject(int) error
-	GetProjectTasks(int) ([]Task, error)
+	UpdateProject(int, Project, int) error
+	DeleteProject(int, int) ([]string, error)
+	GetProjectTasks(int, ListParams) (*Page[Task], error)
+	GetProjectSummary(int) (*ProjectSummary, error)
+	ListProjectMembers(int) ([]ProjectMember, error)
+	GetProjectMember(int, int) (*ProjectMember, error)
+	AddProjectMember(ProjectMember) error
+	RemoveProjectMember(int, int) error
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ListParams selects one page of a listing. Cursor is the NextCursor of the
+// previous page and is only valid together with the same Sort.
+type ListParams struct {
+	Limit  int
+	Cursor string
+	Sort   []SortKey
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+	Total      int    `json:"total"`
+}
+
+// FilterSet matches any of Values, or none of them when Negate is set.
+// An empty set does not filter at all.
+type FilterSet[T any] struct {
+	Values []T
+	Negate bool
+}
+
+// TaskFilter is a task search; all non-empty criteria must match
+type TaskFilter struct {
+	Title         FilterSet[string]
+	Status        FilterSet[string]
+	Category      FilterSet[StatusCategory]
+	Priority      FilterSet[TaskType]
+	Assignee      FilterSet[int]
+	Project       FilterSet[int]
+	Sprint        FilterSet[int]
+	Milestone     FilterSet[int]
+	Team          FilterSet[int]
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	DueAfter      *time.Time
+	DueBefore     *time.Time
+	Label         FilterSet[string]
+	CustomFields  map[string]FieldFilter
+}
+
+// FieldFilter matches a custom field against a set of values, or a range for
+// number and date fields. Values are raw and converted by the field's type.
+type FieldFilter struct {
+	Values FilterSet[string]
+	Min    string
+	Max    string
+}
+
+// TaskQuery is a compiled predicate over the tasks table. Where references
+// Args with $1..$n placeholders and Sort is the order the query asked for.
+type TaskQuery struct {
+	Where string
+	Args  []any
+	Sort  []SortKey
+}
+
+type FilterStore interface {
+	ListUserFilters(int) ([]SavedFilter, error)
+	ListProjectFilters(int) ([]SavedFilter, error)
+	GetFilterById(int) (*SavedFilter, error)
+	CreateFilter(SavedFilter) error
+	UpdateFilter(int, SavedFilter) error
+	DeleteFilter(int) error
+}
+
+type WorkflowStore interface {
+	GetWorkflow(int) (*Workflow, error)
+	UpdateWorkflow(int, Workflow) error
+}
+
+type CommentStore interface {
+	ListTaskComments(int) ([]Comment, error)
+	GetCommentById(int) (*Comment, error)
+	CreateComment(Comment) error
+	UpdateComment(int, string) error
+	DeleteComment(int) error
+	ListCommentRevisions(int) ([]CommentRevision, error)
+	ListMentions(int, ListParams) (*Page[Comment], error)
+}
+
+type AttachmentStore interface {
+	ListTaskAttachments(int) ([]Attachment, error)
+	GetAttachmentById(int) (*Attachment, error)
+	CreateAttachment(Attachment) error
+	DeleteAttachment(int) error
+}
+
+type LinkStore interface {
+	ListTaskLinks(int) ([]TaskLink, error)
+	GetLinkById(int) (*TaskLink, error)
+	CreateLink(TaskLink) error
+	DeleteLink(int) error
+}
+
+type LabelStore interface {
+	ListProjectLabels(int) ([]Label, error)
+	GetLabelById(int) (*Label, error)
+	CreateLabel(Label) error
+	UpdateLabel(int, Label) error
+	DeleteLabel(int, int) error
+	ListTaskLabels(int) ([]Label, error)
+	AttachLabel(int, int, int) error
+	DetachLabel(int, int, int) error
+}
+
+// Custom field values are keyed by field id and hold the value converted
+// to the field's type; nil clears the value
+type CustomFieldStore interface {
+	ListProjectFields(int) ([]CustomField, error)
+	GetFieldById(int) (*CustomField, error)
+	CreateField(CustomField) error
+	UpdateField(int, CustomField) error
+	DeleteField(int) error
+}
+
+type WorklogStore interface {
+	ListTaskWorklogs(int) ([]Worklog, error)
+	GetWorklogById(int) (*Worklog, error)
+	CreateWorklog(Worklog, int) error
+	UpdateWorklog(int, Worklog, int) error
+	DeleteWorklog(int, int) error
+	UserTimeReport(int, Date, Date) (*TimeReport, error)
+	ProjectTimeReport(int, Date, Date) (*TimeReport, error)
+}
+
+type SprintStore interface {
+	ListProjectSprints(int) ([]Sprint, error)
+	GetSprintById(int) (*Sprint, error)
+	CreateSprint(Sprint) error
+	UpdateSprint(int, Sprint) error
+	DeleteSprint(int, int) error
+	StartSprint(int) (*Sprint, error)
+	CompleteSprint(int, *int, int) (*SprintCompletion, error)
+	ListSprintTasks(int, ListParams) (*Page[Task], error)
+	AddTask(int, int, int) error
+	RemoveTask(int, int, int) error
+}
+
+type MilestoneStore interface {
+	ListProjectMilestones(int) ([]Milestone, error)
+	GetMilestoneById(int) (*Milestone, error)
+	CreateMilestone(Milestone) error
+	UpdateMilestone(int, Milestone) error
+	DeleteMilestone(int) error
+	ListMilestoneTasks(int, ListParams) (*Page[Task], error)
+	ReleaseNotes(int, string) ([]ReleaseNoteGroup, error)
+}
+
+type BoardStore interface {
+	GetBoard(int, *int) (*Board, error)
+}
+
+type TeamStore interface {
+	ListTeams(ListParams) (*Page[Team], error)
+	GetTeamById(int) (*Team, error)
+	CreateTeam(Team) error
+	UpdateTeam(int, Team) error
+	DeleteTeam(int) error
+	ListTeamMembers(int) ([]TeamMember, error)
+	AddTeamMember(TeamMember) error
+	RemoveTeamMember(int, int) error
+}
+
+type ReportStore interface {
+	Burndown(int, Date, Date) (*BurndownReport, error)
+	CycleTime(int, Date, Date) (*CycleTimeReport, error)
+	Throughput(int, Date, Date) (*ThroughputReport, error)
+}
+
+type AuditStore interface {
+	ListEvents(AuditFilter, ListParams) (*Page[AuditEvent], error)
+}
+
+// AuditFilter narrows down the audit trail; zero fields do not filter
+type AuditFilter struct {
+	EntityType AuditEntity
+	EntityId   int
+	ActorId    int
+	Action     AuditAction
+	After      *time.Time
+	Before     *time.Time
+}
+
+type SearchStore interface {
+	Search(string, int) (*SearchResults, error)
+}
+
+type CredentialStore interface {
+	GetCredentialsByEmail(string) (*Credentials, error)
 }
 
 type UserRole string
@@ -40,12 +282,67 @@ const (
 	Developer UserRole = "developer"
 )
 
+// User is an account. WeeklyCapacity is the number of hours a week the user
+// can spend on tasks.
 type User struct {
-	ID           int       `json:"id"`
-	FullName     string    `json:"full_name"`
-	Email        string    `json:"email"`
-	RegisterDate time.Time `json:"register_date"`
-	UserRole     UserRole  `json:"user_role"`
+	ID             int       `json:"id"`
+	FullName       string    `json:"full_name"`
+	Email          string    `json:"email"`
+	RegisterDate   time.Time `json:"register_date"`
+	UserRole       UserRole  `json:"user_role"`
+	WeeklyCapacity int       `json:"weekly_capacity"`
+}
+
+// Workload compares the remaining estimates of a user's open tasks with the
+// user's capacity between two days, inclusive, in minutes. The estimate of a
+// task is spread evenly over the days from its start to its due date and
+// capacity over the days of the week; only what falls into the range counts.
+// Tasks without a due date or estimate are not planned and left out.
+type Workload struct {
+	UserId           int               `json:"user_id"`
+	From             Date              `json:"from" swaggertype:"string" format:"date"`
+	To               Date              `json:"to" swaggertype:"string" format:"date"`
+	CapacityMinutes  int               `json:"capacity_minutes"`
+	AllocatedMinutes int               `json:"allocated_minutes"`
+	Utilization      int               `json:"utilization"`
+	OverAllocated    bool              `json:"over_allocated"`
+	Projects         []ProjectWorkload `json:"projects"`
+}
+
+type ProjectWorkload struct {
+	ProjectId        int    `json:"project_id"`
+	Title            string `json:"title"`
+	Tasks            int    `json:"tasks"`
+	AllocatedMinutes int    `json:"allocated_minutes"`
+}
+
+// WorkloadMatrix lays out the workload of several users week by week, see
+// Workload. Weeks start on Monday; the first and the last week only cover
+// the days in the range.
+type WorkloadMatrix struct {
+	From  Date           `json:"from" swaggertype:"string" format:"date"`
+	To    Date           `json:"to" swaggertype:"string" format:"date"`
+	Weeks []Date         `json:"weeks" swaggertype:"array,string"`
+	Users []UserWorkload `json:"users"`
+}
+
+type UserWorkload struct {
+	UserId         int            `json:"user_id"`
+	FullName       string         `json:"full_name"`
+	WeeklyCapacity int            `json:"weekly_capacity"`
+	Weeks          []WorkloadCell `json:"weeks"`
+}
+
+type WorkloadCell struct {
+	Week             Date `json:"week" swaggertype:"string" format:"date"`
+	CapacityMinutes  int  `json:"capacity_minutes"`
+	AllocatedMinutes int  `json:"allocated_minutes"`
+	OverAllocated    bool `json:"over_allocated"`
+}
+
+type Credentials struct {
+	UserId       int
+	PasswordHash string
 }
 
 type TaskType string
@@ -56,72 +353,648 @@ const (
 	High   TaskType = "high"
 )
 
-type TaskPriority string
+// Task is a unit of work. Category is the category of its status in the
+// project's workflow; ParentId is set for subtasks. A task is blocked while
+// any task that blocks it is not done. CompletedAt is set while the task is
+// in a done state. SprintId is nil while the task is in the project's
+// backlog; MilestoneId is the milestone of the project it is planned for.
+// Estimates and the time spent are in minutes.
+type Task struct {
+	ID                int            `json:"id"`
+	Title             string         `json:"title"`
+	Descript          string         `json:"descript"`
+	TaskType          TaskType       `json:"task_type"`
+	Status            string         `json:"status"`
+	Category          StatusCategory `json:"category"`
+	Blocked           bool           `json:"blocked"`
+	UserId            int            `json:"user_id"`
+	ProjectId         int            `json:"project_id"`
+	ParentId          *int           `json:"parent_id"`
+	SprintId          *int           `json:"sprint_id"`
+	MilestoneId       *int           `json:"milestone_id"`
+	Rank              string         `json:"rank"`
+	Labels            []string       `json:"labels"`
+	CustomFields      map[string]any `json:"custom_fields"`
+	StartDate         *Date          `json:"start_date" swaggertype:"string" format:"date"`
+	DueDate           *Date          `json:"due_date" swaggertype:"string" format:"date"`
+	CompletedAt       *time.Time     `json:"completed_at"`
+	OriginalEstimate  *int           `json:"original_estimate"`
+	RemainingEstimate *int           `json:"remaining_estimate"`
+	TimeSpent         int            `json:"time_spent"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// TaskTree is a task with its subtasks. Progress is the rolled up completion
+// percentage of the task.
+type TaskTree struct {
+	Task
+	Progress int        `json:"progress"`
+	Children []TaskTree `json:"children"`
+}
+
+// StatusCategory groups the project specific workflow states into the
+// three phases every workflow has in common
+type StatusCategory string
 
 const (
-	New        TaskPriority = "new"
-	In_Process TaskPriority = "in_process"
-	Done       TaskPriority = "done"
+	Todo       StatusCategory = "todo"
+	InProgress StatusCategory = "in_progress"
+	Done       StatusCategory = "done"
 )
 
-type Task struct {
-	ID           int          `json:"id"`
-	Title        string       `json:"title"`
-	Descript     string       `json:"descript"`
-	TaskType     TaskType     `json:"task_type"`
-	TaskPriority TaskPriority `json:"task_priority"`
-	UserId       int          `json:"user_id"`
-	ProjectId    int          `json:"project_id"`
-	CreatedAt    time.Time    `json:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at"`
+// WorkflowState is a state of a project's workflow and a column of its board.
+// A task cannot move into a state that already holds WipLimit tasks.
+type WorkflowState struct {
+	Name     string         `json:"name"`
+	Category StatusCategory `json:"category"`
+	WipLimit *int           `json:"wip_limit"`
+}
+
+type WorkflowTransition struct {
+	From string `json:"from" validate:"required"`
+	To   string `json:"to" validate:"required"`
 }
 
+// Workflow is the set of states a project's tasks can be in, in display
+// order, and the moves allowed between them. New tasks start in the first state.
+type Workflow struct {
+	ProjectId   int                  `json:"project_id"`
+	States      []WorkflowState      `json:"states"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+func (w *Workflow) State(name string) (*WorkflowState, bool) {
+	for i := range w.States {
+		if w.States[i].Name == name {
+			return &w.States[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the states a task in the given state may move to
+func (w *Workflow) Next(from string) []string {
+	next := []string{}
+	for _, transition := range w.Transitions {
+		if transition.From == from {
+			next = append(next, transition.To)
+		}
+	}
+
+	return next
+}
+
+// Project is a body of work managed by ManagerId. TeamId is the team that
+// owns the project, if any.
 type Project struct {
+	ID           int           `json:"id"`
+	Title        string        `json:"title"`
+	Descript     string        `json:"descript"`
+	ManagerId    int           `json:"manager_id"`
+	TeamId       *int          `json:"team_id"`
+	CustomFields []CustomField `json:"custom_fields"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// ProjectSummary is an overview of a project's tasks for its dashboard.
+// Completion is the percentage of tasks that are done.
+type ProjectSummary struct {
+	ProjectId       int             `json:"project_id"`
+	Total           int             `json:"total"`
+	Done            int             `json:"done"`
+	Overdue         int             `json:"overdue"`
+	Completion      int             `json:"completion"`
+	ByStatus        []StatusCount   `json:"by_status"`
+	ByTaskType      []TaskTypeCount `json:"by_task_type"`
+	ByAssignee      []AssigneeCount `json:"by_assignee"`
+	RecentlyUpdated []RecentTask    `json:"recently_updated"`
+}
+
+type StatusCount struct {
+	Status   string         `json:"status"`
+	Category StatusCategory `json:"category"`
+	Count    int            `json:"count"`
+}
+
+type TaskTypeCount struct {
+	TaskType TaskType `json:"task_type"`
+	Count    int      `json:"count"`
+}
+
+type AssigneeCount struct {
+	UserId   int    `json:"user_id"`
+	FullName string `json:"full_name"`
+	Count    int    `json:"count"`
+	Overdue  int    `json:"overdue"`
+}
+
+type RecentTask struct {
 	ID        int       `json:"id"`
 	Title     string    `json:"title"`
-	Descript  string    `json:"descript"`
-	ManagerId int       `json:"manager_id"`
+	Status    string    `json:"status"`
+	UserId    int       `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CustomFieldType string
+
+const (
+	FieldText   CustomFieldType = "text"
+	FieldNumber CustomFieldType = "number"
+	FieldDate   CustomFieldType = "date"
+	FieldEnum   CustomFieldType = "enum"
+	FieldUser   CustomFieldType = "user"
+)
+
+// CustomField is a typed attribute a project defines for its tasks. Options
+// lists the allowed values of an enum field.
+type CustomField struct {
+	ID        int             `json:"id"`
+	ProjectId int             `json:"project_id"`
+	Name      string          `json:"name"`
+	Type      CustomFieldType `json:"type"`
+	Options   []string        `json:"options"`
+}
+
+type ProjectRole string
+
+const (
+	Owner       ProjectRole = "owner"
+	Maintainer  ProjectRole = "maintainer"
+	Contributor ProjectRole = "contributor"
+	Viewer      ProjectRole = "viewer"
+)
+
+type ProjectMember struct {
+	ProjectId   int         `json:"project_id"`
+	UserId      int         `json:"user_id"`
+	ProjectRole ProjectRole `json:"project_role"`
+	AddedAt     time.Time   `json:"added_at"`
+}
+
+// Team is a group of users. The lead, if any, is always one of its members.
+type Team struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Descript    string    `json:"descript"`
+	LeadId      *int      `json:"lead_id"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TeamMember struct {
+	TeamId  int       `json:"team_id"`
+	UserId  int       `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// SavedFilter is a named task query owned by a user. When ProjectId is set the
+// filter is shared with the members of that project.
+type SavedFilter struct {
+	ID        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	ProjectId *int      `json:"project_id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Comment is a note on a task, optionally in reply to another comment on the
+// same task. Mentions are the ids of the users mentioned as @email in the body.
+// Replies is only filled in when comments are listed as threads.
+type Comment struct {
+	ID        int       `json:"id"`
+	TaskId    int       `json:"task_id"`
+	ParentId  *int      `json:"parent_id"`
+	AuthorId  *int      `json:"author_id"`
+	Body      string    `json:"body"`
+	Mentions  []int     `json:"mentions"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Replies   []Comment `json:"replies,omitempty"`
+}
+
+// CommentRevision is a previous body of an edited comment
+type CommentRevision struct {
+	ID        int       `json:"id"`
+	CommentId int       `json:"comment_id"`
+	Body      string    `json:"body"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// Worklog is time a user spent on a task
+type Worklog struct {
+	ID              int       `json:"id"`
+	TaskId          int       `json:"task_id"`
+	UserId          *int      `json:"user_id"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Note            string    `json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TimeReport sums up the time logged between two days, inclusive. Groups
+// break the total down by project for a user, or by user for a project.
+type TimeReport struct {
+	From         Date        `json:"from" swaggertype:"string" format:"date"`
+	To           Date        `json:"to" swaggertype:"string" format:"date"`
+	TotalMinutes int         `json:"total_minutes"`
+	Groups       []TimeGroup `json:"groups"`
+}
+
+type TimeGroup struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Minutes int    `json:"minutes"`
+}
+
+// Attachment is the metadata of a file uploaded to a task. The content lives
+// in file storage under StorageKey.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskId      int       `json:"task_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	UploaderId  *int      `json:"uploader_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Label is a coloured tag from a project's catalogue that the project's tasks can carry
+type Label struct {
+	ID        int       `json:"id"`
+	ProjectId int       `json:"project_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SprintState string
+
+const (
+	SprintPlanned SprintState = "planned"
+	SprintActive  SprintState = "active"
+	SprintClosed  SprintState = "closed"
+)
+
+// Sprint is a time box of a project's work. A sprint is planned until it is
+// started and closed once completed; a project has at most one active sprint.
+type Sprint struct {
+	ID          int         `json:"id"`
+	ProjectId   int         `json:"project_id"`
+	Name        string      `json:"name"`
+	Goal        string      `json:"goal"`
+	StartDate   *Date       `json:"start_date" swaggertype:"string" format:"date"`
+	EndDate     *Date       `json:"end_date" swaggertype:"string" format:"date"`
+	State       SprintState `json:"state"`
+	CompletedAt *time.Time  `json:"completed_at"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// SprintCompletion is the outcome of completing a sprint. Unfinished tasks
+// were carried over to the sprint CarriedOverTo, or to the backlog when nil.
+type SprintCompletion struct {
+	Sprint        Sprint `json:"sprint"`
+	Completed     int    `json:"completed"`
+	CarriedOver   int    `json:"carried_over"`
+	CarriedOverTo *int   `json:"carried_over_to"`
+}
+
+// Milestone is a target of a project that its tasks can be planned towards.
+// Progress is the percentage of its tasks that are done and RemainingEstimate
+// the minutes left on the open ones.
+type Milestone struct {
+	ID                int       `json:"id"`
+	ProjectId         int       `json:"project_id"`
+	Title             string    `json:"title"`
+	Descript          string    `json:"descript"`
+	TargetDate        *Date     `json:"target_date" swaggertype:"string" format:"date"`
+	TotalTasks        int       `json:"total_tasks"`
+	DoneTasks         int       `json:"done_tasks"`
+	Progress          int       `json:"progress"`
+	RemainingEstimate int       `json:"remaining_estimate"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// ReleaseNotes lists the done tasks of a milestone, grouped by label or task type
+type ReleaseNotes struct {
+	Milestone Milestone          `json:"milestone"`
+	GroupBy   string             `json:"group_by"`
+	Groups    []ReleaseNoteGroup `json:"groups"`
+}
+
+type ReleaseNoteGroup struct {
+	Name  string `json:"name"`
+	Tasks []Task `json:"tasks"`
+}
+
+// TaskMove places a task on its project's board: into the column Status,
+// right after the task AfterId or right before the task BeforeId, or at the
+// bottom of the column when neither is given
+type TaskMove struct {
+	Status   string
+	AfterId  *int
+	BeforeId *int
+}
+
+// Board shows a project's tasks in a column per workflow state, in rank
+// order. Count is the number of the project's tasks in the column, which is
+// what the WIP limit applies to, even when the board only shows a sprint.
+type Board struct {
+	ProjectId int           `json:"project_id"`
+	SprintId  *int          `json:"sprint_id"`
+	Columns   []BoardColumn `json:"columns"`
+}
+
+type BoardColumn struct {
+	Status   string         `json:"status"`
+	Category StatusCategory `json:"category"`
+	WipLimit *int           `json:"wip_limit"`
+	Count    int            `json:"count"`
+	Tasks    []Task         `json:"tasks"`
+}
+
+// BurndownReport follows the tasks of a project as they were at the end of
+// each day between two days, inclusive. Remaining is the burndown series;
+// Done against Scope the burnup one.
+type BurndownReport struct {
+	From   Date            `json:"from" swaggertype:"string" format:"date"`
+	To     Date            `json:"to" swaggertype:"string" format:"date"`
+	Points []BurndownPoint `json:"points"`
+}
+
+type BurndownPoint struct {
+	Date      Date `json:"date" swaggertype:"string" format:"date"`
+	Scope     int  `json:"scope"`
+	Done      int  `json:"done"`
+	Remaining int  `json:"remaining"`
+}
+
+// CycleTimeReport covers the tasks completed between two days, inclusive.
+// Lead time runs from the creation of a task to its completion, cycle time
+// from the moment work on it first started.
+type CycleTimeReport struct {
+	From      Date        `json:"from" swaggertype:"string" format:"date"`
+	To        Date        `json:"to" swaggertype:"string" format:"date"`
+	Completed int         `json:"completed"`
+	CycleTime Percentiles `json:"cycle_time"`
+	LeadTime  Percentiles `json:"lead_time"`
+}
+
+// Percentiles of a duration in hours, nil when there is nothing to measure
+type Percentiles struct {
+	Count int      `json:"count"`
+	P50   *float64 `json:"p50"`
+	P85   *float64 `json:"p85"`
+	P95   *float64 `json:"p95"`
+}
+
+// ThroughputReport counts the tasks completed per week between two days,
+// inclusive. Weeks start on Monday.
+type ThroughputReport struct {
+	From  Date             `json:"from" swaggertype:"string" format:"date"`
+	To    Date             `json:"to" swaggertype:"string" format:"date"`
+	Weeks []ThroughputWeek `json:"weeks"`
+}
+
+type ThroughputWeek struct {
+	Week      Date `json:"week" swaggertype:"string" format:"date"`
+	Completed int  `json:"completed"`
+}
+
+type LinkType string
+
+const (
+	Blocks     LinkType = "blocks"
+	RelatesTo  LinkType = "relates_to"
+	Duplicates LinkType = "duplicates"
+)
+
+// TaskLink is a typed link from one task to another, e.g. TaskId blocks TargetId
+type TaskLink struct {
+	ID        int       `json:"id"`
+	TaskId    int       `json:"task_id"`
+	TargetId  int       `json:"target_id"`
+	Type      LinkType  `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditEntity string
+
+const (
+	AuditTask    AuditEntity = "task"
+	AuditProject AuditEntity = "project"
+	AuditUser    AuditEntity = "user"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// AuditEvent is an immutable record of a change. Changes is keyed by the JSON
+// name of each changed field. ActorId is null for changes made by the system.
+type AuditEvent struct {
+	ID         int                    `json:"id"`
+	EntityType AuditEntity            `json:"entity_type"`
+	EntityId   int                    `json:"entity_id"`
+	Action     AuditAction            `json:"action"`
+	ActorId    *int                   `json:"actor_id"`
+	Changes    map[string]FieldChange `json:"changes"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// SearchHit is a full-text match. Highlight is an HTML excerpt of the
+// matched text: the text is escaped and the matching terms are wrapped in
+// <mark> tags. Title is plain text.
+type SearchHit struct {
+	ID        int     `json:"id"`
+	Title     string  `json:"title"`
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+type SearchResults struct {
+	Query    string      `json:"query"`
+	Tasks    []SearchHit `json:"tasks"`
+	Projects []SearchHit `json:"projects"`
+	Users    []SearchHit `json:"users"`
+}
+
 type CreateUserPayload struct {
 	FullName string   `json:"full_name" validate:"required"`
 	Email    string   `json:"email" validate:"required"`
+	Password string   `json:"password" validate:"required,min=8"`
 	UserRole UserRole `json:"user_role" validate:"required"`
 }
 
 type UpdateUserPayload struct {
-	FullName string   `json:"full_name" validate:"omitempty"`
-	UserRole UserRole `json:"user_role" validate:"omitempty"`
+	FullName       string   `json:"full_name" validate:"omitempty"`
+	UserRole       UserRole `json:"user_role" validate:"omitempty"`
+	WeeklyCapacity *int     `json:"weekly_capacity" validate:"omitempty,min=0,max=168"`
 }
 
 type CreateTaskPayload struct {
-	Title        string       `json:"title" validate:"required"`
-	Descript     string       `json:"descript" validate:"omitempty"`
-	TaskType     TaskType     `json:"task_type" validate:"required"`
-	TaskPriority TaskPriority `json:"task_priority" validate:"required"`
-	UserId       int          `json:"user_id" validate:"required"`
-	ProjectId    int          `json:"project_id" validate:"required"`
+	Title             string   `json:"title" validate:"required"`
+	Descript          string   `json:"descript" validate:"omitempty"`
+	TaskType          TaskType `json:"task_type" validate:"required"`
+	UserId            int      `json:"user_id" validate:"required"`
+	ProjectId         int      `json:"project_id" validate:"required"`
+	ParentId          *int     `json:"parent_id" validate:"omitempty"`
+	StartDate         *Date    `json:"start_date" validate:"omitempty" swaggertype:"string" format:"date"`
+	DueDate           *Date    `json:"due_date" validate:"omitempty" swaggertype:"string" format:"date"`
+	OriginalEstimate  *int     `json:"original_estimate" validate:"omitempty,min=0"`
+	RemainingEstimate *int     `json:"remaining_estimate" validate:"omitempty,min=0"`
+	MilestoneId       *int     `json:"milestone_id" validate:"omitempty"`
 }
 
 type UpdateTaskPayload struct {
-	Title        string       `json:"title" validate:"required"`
-	Descript     string       `json:"descript" validate:"required"`
-	TaskType     TaskType     `json:"task_type" validate:"required"`
-	TaskPriority TaskPriority `json:"task_priority" validate:"required"`
-	UserId       int          `json:"user_id" validate:"required"`
-	ProjectId    int          `json:"project_id" validate:"required"`
+	Title             string   `json:"title" validate:"required"`
+	Descript          string   `json:"descript" validate:"required"`
+	TaskType          TaskType `json:"task_type" validate:"required"`
+	UserId            int      `json:"user_id" validate:"required"`
+	ProjectId         int      `json:"project_id" validate:"required"`
+	ParentId          *int     `json:"parent_id" validate:"omitempty"`
+	StartDate         *Date    `json:"start_date" validate:"omitempty" swaggertype:"string" format:"date"`
+	DueDate           *Date    `json:"due_date" validate:"omitempty" swaggertype:"string" format:"date"`
+	OriginalEstimate  *int     `json:"original_estimate" validate:"omitempty,min=0"`
+	RemainingEstimate *int     `json:"remaining_estimate" validate:"omitempty,min=0"`
+	MilestoneId       *int     `json:"milestone_id" validate:"omitempty"`
+}
+
+type TransitionTaskPayload struct {
+	Status string `json:"status" validate:"required"`
+}
+
+type MoveTaskPayload struct {
+	Status   string `json:"status" validate:"omitempty"`
+	AfterId  *int   `json:"after_id" validate:"omitempty"`
+	BeforeId *int   `json:"before_id" validate:"omitempty"`
 }
 
 type CreateProjectPayload struct {
 	Title     string `json:"title" validate:"required"`
 	Descript  string `json:"descript" validate:"omitempty"`
 	ManagerId int    `json:"manager_id" validate:"required"`
+	TeamId    *int   `json:"team_id" validate:"omitempty"`
 }
 
 type UpdateProjectPayload struct {
 	Title     string `json:"title" validate:"required"`
 	Descript  string `json:"descript" validate:"required"`
 	ManagerId int    `json:"manager_id" validate:"required"`
+	TeamId    *int   `json:"team_id" validate:"omitempty"`
+}
+
+type TeamPayload struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Descript string `json:"descript" validate:"omitempty"`
+	LeadId   *int   `json:"lead_id" validate:"omitempty"`
+}
+
+type AddTeamMemberPayload struct {
+	UserId int `json:"user_id" validate:"required"`
+}
+
+type AddProjectMemberPayload struct {
+	UserId      int         `json:"user_id" validate:"required"`
+	ProjectRole ProjectRole `json:"project_role" validate:"required,oneof=owner maintainer contributor viewer"`
+}
+
+type WorkflowStatePayload struct {
+	Name     string         `json:"name" validate:"required,max=30"`
+	Category StatusCategory `json:"category" validate:"required,oneof=todo in_progress done"`
+	WipLimit *int           `json:"wip_limit" validate:"omitempty,min=1"`
+}
+
+type WorkflowPayload struct {
+	States      []WorkflowStatePayload `json:"states" validate:"required,min=1,dive"`
+	Transitions []WorkflowTransition   `json:"transitions" validate:"dive"`
+}
+
+type CreateCommentPayload struct {
+	Body     string `json:"body" validate:"required,max=10000"`
+	ParentId *int   `json:"parent_id" validate:"omitempty"`
+}
+
+type UpdateCommentPayload struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+type CustomFieldPayload struct {
+	Name    string          `json:"name" validate:"required,max=50" example:"story_points"`
+	Type    CustomFieldType `json:"type" validate:"required,oneof=text number date enum user"`
+	Options []string        `json:"options" validate:"omitempty,unique,dive,required,max=50"`
+}
+
+type MilestonePayload struct {
+	Title      string `json:"title" validate:"required,max=100"`
+	Descript   string `json:"descript" validate:"max=5000"`
+	TargetDate *Date  `json:"target_date" validate:"omitempty" swaggertype:"string" format:"date"`
+}
+
+type SprintPayload struct {
+	Name      string `json:"name" validate:"required,max=100"`
+	Goal      string `json:"goal" validate:"max=2000"`
+	StartDate *Date  `json:"start_date" validate:"omitempty" swaggertype:"string" format:"date"`
+	EndDate   *Date  `json:"end_date" validate:"omitempty" swaggertype:"string" format:"date"`
+}
+
+type CompleteSprintPayload struct {
+	CarryOverTo *int `json:"carry_over_to" validate:"omitempty"`
+}
+
+type WorklogPayload struct {
+	StartedAt       time.Time `json:"started_at" validate:"required"`
+	DurationMinutes int       `json:"duration_minutes" validate:"required,min=1,max=1440"`
+	Note            string    `json:"note" validate:"max=2000"`
+}
+
+type LabelPayload struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"required,hexcolor,len=7" example:"#d73a4a"`
+}
+
+type CreateTaskLinkPayload struct {
+	TargetId int      `json:"target_id" validate:"required"`
+	Type     LinkType `json:"type" validate:"required,oneof=blocks relates_to duplicates"`
+}
+
+type SavedFilterPayload struct {
+	Name      string `json:"name" validate:"required,max=100"`
+	Query     string `json:"query" validate:"required"`
+	ProjectId *int   `json:"project_id" validate:"omitempty"`
+}
+
+type LoginPayload struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
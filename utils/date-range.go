@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+// Reads the required from and to days of the query string. A positive maxDays
+// limits how many days the range may cover.
+func ParseDateRange(r *http.Request, maxDays int) (types.Date, types.Date, error) {
+	query := r.URL.Query()
+
+	if query.Get("from") == "" || query.Get("to") == "" {
+		return types.Date{}, types.Date{}, fmt.Errorf("from and to are required")
+	}
+
+	from, err := types.ParseDate(query.Get("from"))
+	if err != nil {
+		return types.Date{}, types.Date{}, fmt.Errorf("invalid from: %v", err)
+	}
+
+	to, err := types.ParseDate(query.Get("to"))
+	if err != nil {
+		return types.Date{}, types.Date{}, fmt.Errorf("invalid to: %v", err)
+	}
+
+	if to.Before(from.Time) {
+		return types.Date{}, types.Date{}, fmt.Errorf("to must not be before from")
+	}
+
+	if maxDays > 0 && to.Sub(from.Time).Hours()/24 >= float64(maxDays) {
+		return types.Date{}, types.Date{}, fmt.Errorf("the range covers at most %d days", maxDays)
+	}
+
+	return from, to, nil
+}
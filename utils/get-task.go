@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/4lerman/pm_service/types"
+	"github.com/gorilla/mux"
+)
+
+// Loads the task whose id is in the path. When it cannot, the error response
+// is written and false is returned.
+func GetTask(w http.ResponseWriter, r *http.Request, store types.TaskStore) (*types.Task, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if id == "" {
+		WriteError(w, http.StatusBadRequest, fmt.Errorf("id is not indicated"))
+		return nil, false
+	}
+
+	taskId, _ := strconv.Atoi(id)
+
+	task, err := store.GetTaskById(taskId)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, fmt.Errorf("failed to get task by id: %v", err))
+		return nil, false
+	}
+
+	return task, true
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4lerman/pm_service/types"
+)
+
+// Reads limit, cursor and sort query parameters. Sort is a comma separated
+// list of fields, each optionally prefixed with "-" for descending order,
+// e.g. sort=-updated_at,title
+func ParseListParams(r *http.Request) (types.ListParams, error) {
+	query := r.URL.Query()
+	params := types.ListParams{Cursor: query.Get("cursor")}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return params, fmt.Errorf("%w: limit must be a positive integer", types.ErrInvalidListParams)
+		}
+
+		params.Limit = value
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			field = strings.TrimSpace(field)
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+
+			if field == "" {
+				return params, fmt.Errorf("%w: empty sort field", types.ErrInvalidListParams)
+			}
+
+			params.Sort = append(params.Sort, types.SortKey{Field: field, Desc: desc})
+		}
+	}
+
+	return params, nil
+}
+
+// Maps errors returned by paginated store methods to a response status
+func ListErrorStatus(err error) int {
+	if errors.Is(err, types.ErrInvalidListParams) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}